@@ -3,8 +3,8 @@ module infracourse.cloud/a2-grader/a2-orchestrator
 go 1.21.6
 
 require (
-	github.com/go-git/go-git/v5 v5.11.0
-	github.com/open-policy-agent/opa v0.61.0
+	infracourse.cloud/a2-grader/grader v0.0.0
+	infracourse.cloud/a2-grader/rules v0.0.0
 )
 
 require (
@@ -26,6 +26,7 @@ require (
 	github.com/emirpasic/gods v1.18.1 // indirect
 	github.com/go-git/gcfg v1.5.1-0.20230307220236-3a3c6141e376 // indirect
 	github.com/go-git/go-billy/v5 v5.5.0 // indirect
+	github.com/go-git/go-git/v5 v5.11.0 // indirect
 	github.com/go-ini/ini v1.67.0 // indirect
 	github.com/go-logr/logr v1.4.1 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
@@ -43,6 +44,7 @@ require (
 	github.com/matttproud/golang_protobuf_extensions/v2 v2.0.0 // indirect
 	github.com/mholt/archiver/v4 v4.0.0-alpha.8 // indirect
 	github.com/nwaples/rardecode/v2 v2.0.0-beta.2 // indirect
+	github.com/open-policy-agent/opa v0.61.0 // indirect
 	github.com/pierrec/lz4/v4 v4.1.15 // indirect
 	github.com/pjbgf/sha1cd v0.3.0 // indirect
 	github.com/prometheus/client_golang v1.18.0 // indirect
@@ -76,3 +78,8 @@ require (
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	sigs.k8s.io/yaml v1.4.0 // indirect
 )
+
+replace (
+	infracourse.cloud/a2-grader/grader => ../grader
+	infracourse.cloud/a2-grader/rules => ../rules
+)
@@ -1,189 +1,51 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
-	"strings"
 
-	"github.com/go-git/go-git/v5"
-	"github.com/mholt/archiver/v4"
-	"github.com/open-policy-agent/opa/rego"
+	"infracourse.cloud/a2-grader/grader"
+	"infracourse.cloud/a2-grader/grader/yoctogram"
+	"infracourse.cloud/a2-grader/rules"
 )
 
-const LAMBDA_GATEWAY_URI = "https://5tvpsbxptgyc6m7ffmgmxvdw7m0pbmkb.lambda-url.us-east-1.on.aws/"
-const GRADER_TOKEN = "INSECURE-CHANGE-BEFORE-RELEASE"
-
-func getSunet() (string, error) {
-	contents, err := os.ReadFile("/autograder/submission/SUNET")
-	if err != nil {
-		log.Println(err)
-		return "", err
-	}
-
-	return strings.TrimSpace(string(contents)), nil
-}
-
-func makeSubmissionZip() ([]byte, error) {
-	err := os.Chdir("/autograder/submission")
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	// Make directories that would otherwise be Git submodules, but not included by Gradescope submission
-	err = os.MkdirAll("web/dist", 0777)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	_, err = git.PlainClone("/autograder/submission/app", false, &git.CloneOptions{
-		URL: "https://github.com/infracourse/yoctogram-app.git",
-	})
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	files, err := archiver.FilesFromDisk(nil, map[string]string{
-		".": "",
-	})
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	buf := &bytes.Buffer{}
-	format := archiver.Zip{}
-	err = format.Archive(context.TODO(), buf, files)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
-}
-
-type GradingLambdaRequest struct {
-	File []byte
-}
-
-func getCfnResources(gradingLambdaURI string, submissionZip []byte) (map[string]interface{}, error) {
-	request := GradingLambdaRequest{File: submissionZip}
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(request)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	resp, err := http.Post(gradingLambdaURI, "application/json", buf)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		log.Println("HTTP status code", resp.StatusCode)
-		return nil, fmt.Errorf("synthesizer lambda returned HTTP status code %d", resp.StatusCode)
-	}
-	defer resp.Body.Close()
-
-	var resources map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&resources)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	return resources, nil
-}
-
-func getOpaEvaluator() (func(r *rego.Rego), error) {
-	_, err := git.PlainClone("/grader", false, &git.CloneOptions{
-		URL: "https://github.com/infracourse/iac-grader.git",
-	})
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	return rego.LoadBundle("/grader/a3-rules"), nil
-}
-
-type GradescopeTest struct {
-	Score    float64 `json:"score"`
-	MaxScore float64 `json:"max_score"`
-	Name     string  `json:"name"`
-	Output   string  `json:"output"`
-}
-
-type RuntimeCheckOutput struct {
-	RuntimeGrade int              `json:"runtime_grade"`
-	Results      []GradescopeTest `json:"results"`
+type ValidateResponse struct {
+	Correct bool `json:"correct"`
 }
 
-func doRuntimeCheck() (RuntimeCheckOutput, error) {
-	cmd := exec.Command("python3", "/autograder/runtime/grade.py")
-	if cmd.Err != nil {
-		log.Println(cmd.Err)
-		return RuntimeCheckOutput{}, cmd.Err
-	}
-
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-
-	if err := cmd.Run(); err != nil {
-		log.Println(err)
-		return RuntimeCheckOutput{}, err
+func validateFlag(ctx context.Context, options grader.Options, submission grader.Submission) (bool, error) {
+	if options.GraderToken == "" {
+		return false, errors.New("no grader token is configured; set GRADER_TOKEN or grader_token in the config file")
 	}
 
-	var results RuntimeCheckOutput
-	err := json.Unmarshal(stdout.Bytes(), &results)
+	submittedFlag, err := submission.ReadFile("FLAG")
 	if err != nil {
 		log.Println(err)
-		return results, err
-	}
-
-	return results, nil
-}
-
-func getSubmittedFlag() (string, error) {
-	contents, err := os.ReadFile("/autograder/submission/FLAG")
-	if err != nil {
-		log.Println(err)
-		return "", err
+		return false, err
 	}
 
-	return strings.TrimSpace(string(contents)), nil
-}
-
-type ValidateResponse struct {
-	Correct bool `json:"correct"`
-}
-
-func validateFlag() (bool, error) {
-	submittedFlag, err := getSubmittedFlag()
+	sunet, err := submission.ReadFile("SUNET")
 	if err != nil {
 		log.Println(err)
 		return false, err
 	}
 
-	sunet, err := getSunet()
+	validateURL, err := url.JoinPath(options.FlagValidationURL, sunet)
 	if err != nil {
 		log.Println(err)
 		return false, err
 	}
 
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
-		fmt.Sprintf("https://provisiondns.infracourse.cloud/a3/grader/%s?flag=%s", sunet, submittedFlag),
+		validateURL+"?"+url.Values{"flag": {submittedFlag}}.Encode(),
 		nil,
 	)
 	if err != nil {
@@ -191,13 +53,14 @@ func validateFlag() (bool, error) {
 		return false, err
 	}
 
-	req.Header.Set("X-Grader-Token", GRADER_TOKEN)
+	req.Header.Set("X-Grader-Token", string(options.GraderToken))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := grader.HTTPClient.Do(req)
 	if err != nil {
 		log.Println(err)
 		return false, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		log.Println("HTTP status code", resp.StatusCode)
@@ -214,98 +77,53 @@ func validateFlag() (bool, error) {
 	return validateResponse.Correct, nil
 }
 
-type GradescopeOutput struct {
-	Score float64          `json:"score"`
-	Tests []GradescopeTest `json:"tests"`
+func flagCheck(options grader.Options) func(context.Context, grader.Submission) (grader.CheckResult, error) {
+	return func(ctx context.Context, submission grader.Submission) (grader.CheckResult, error) {
+		validateResult, err := validateFlag(ctx, options, submission)
+		if err != nil {
+			log.Println(err)
+			return grader.CheckResult{}, err
+		}
+
+		validateTest := grader.GradescopeTest{
+			Score:    0.0,
+			MaxScore: 34.0,
+			Name:     "Validate flag from Datadog",
+		}
+		if validateResult {
+			validateTest.Score = 34.0
+		}
+
+		return grader.CheckResult{
+			Score: validateTest.Score,
+			Tests: []grader.GradescopeTest{validateTest},
+		}, nil
+	}
 }
 
 func main() {
-	submissionZip, err := makeSubmissionZip()
+	options, err := grader.ParseOptions(os.Args[1:])
 	if err != nil {
-		log.Println(err)
-		return
+		_ = grader.WriteFailure(options, err)
+		log.Fatalln(err)
 	}
 
-	resources, err := getCfnResources(LAMBDA_GATEWAY_URI, submissionZip)
+	manifest, err := grader.LoadManifest(options.ManifestPath)
 	if err != nil {
 		log.Println(err)
+		_ = grader.WriteFailure(options, err)
 		return
 	}
 
-	evaluator, err := getOpaEvaluator()
+	err = grader.Run(grader.Assignment{
+		Manifest: manifest,
+		Rules:    rules.FS,
+		Checks: []grader.Check{
+			yoctogram.CompressionCheck("runtime", options),
+			{Name: "flag", MaxScore: 34.0, Run: flagCheck(options)},
+		},
+	}, options)
 	if err != nil {
 		log.Println(err)
-		return
 	}
-
-	query, err := rego.New(
-		evaluator,
-		rego.Query("data.rules.main"),
-	).PrepareForEval(context.TODO())
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	results, err := query.Eval(context.TODO(), rego.EvalInput(resources))
-	if err != nil || len(results) == 0 {
-		log.Println(err)
-		return
-	}
-
-	failures := results[0].Expressions[0].Value.(map[string]interface{})["violations"].([]interface{})
-
-	runtimeResults, err := doRuntimeCheck()
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	// TODO
-	validateResult, err := validateFlag()
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	flagDeduction := 34.0
-	if validateResult {
-		flagDeduction = 0.0
-	}
-
-	gradescopeFormattedOutput := GradescopeOutput{
-		Score: 150.0 - (4.0 * float64(len(failures))) - (60.0 - float64(runtimeResults.RuntimeGrade)) - flagDeduction,
-		Tests: make([]GradescopeTest, 0, len(failures)+len(runtimeResults.Results)),
-	}
-
-	for _, failure := range failures {
-		gradescopeFormattedOutput.Tests = append(
-			gradescopeFormattedOutput.Tests,
-			GradescopeTest{
-				Score:    0,
-				MaxScore: 2.0,
-				Name:     fmt.Sprintf("%v", failure),
-			},
-		)
-	}
-
-	validateTest := GradescopeTest{
-		Score:    0.0,
-		MaxScore: 34.0,
-		Name:     "Validate flag from Datadog",
-	}
-	if validateResult {
-		validateTest.Score = 34.0
-	}
-
-	gradescopeFormattedOutput.Tests = append(gradescopeFormattedOutput.Tests, runtimeResults.Results...)
-	gradescopeFormattedOutput.Tests = append(gradescopeFormattedOutput.Tests, validateTest)
-
-	output, err := json.MarshalIndent(gradescopeFormattedOutput, "", "  ")
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	_ = os.WriteFile("/autograder/results/results.json", output, 0777)
 }
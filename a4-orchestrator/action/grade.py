@@ -1,4 +1,5 @@
 import json
+import os
 import re
 import sys
 from typing import List
@@ -41,20 +42,33 @@ TESTS: List[GradescopeTest] = [
 ]
 
 
+# Check plugin input from the orchestrator; see grader/plugin.go
+PROTOCOL_VERSION = 1
+PLUGIN_INPUT = json.load(sys.stdin)
+if PLUGIN_INPUT["protocol_version"] != PROTOCOL_VERSION:
+    raise SystemExit(f"unsupported protocol version {PLUGIN_INPUT['protocol_version']}")
+
+
 def finalize_grades() -> None:
     final_grade = sum([test.score for test in TESTS])
-    print(
-        json.dumps(
-            {"actions_grade": final_grade, "results": TESTS},
+    with open(PLUGIN_INPUT["output_path"], "w") as output:
+        json.dump(
+            {
+                "protocol_version": PROTOCOL_VERSION,
+                "score": final_grade,
+                "tests": TESTS,
+            },
+            output,
             cls=CustomEncoder,
             indent=2,
         )
-    )
     exit(0)
 
 
 # Read YAML content from file
-yaml_content = open(sys.argv[1], "r").read()
+yaml_content = open(
+    os.path.join(PLUGIN_INPUT["submission_dir"], "main.yml"), "r"
+).read()
 
 # Define hardcoded env variables
 values = {
@@ -1,217 +1,35 @@
 package main
 
 import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"fmt"
 	"log"
-	"net/http"
 	"os"
-	"os/exec"
 
-	"github.com/go-git/go-git/v5"
-	"github.com/mholt/archiver/v4"
-	"github.com/open-policy-agent/opa/rego"
+	"infracourse.cloud/a2-grader/grader"
+	"infracourse.cloud/a2-grader/rules"
 )
 
-const LAMBDA_GATEWAY_URI = "https://5tvpsbxptgyc6m7ffmgmxvdw7m0pbmkb.lambda-url.us-east-1.on.aws/"
-const GRADER_TOKEN = "9007b79f4f9927ea8479076f4fdbec6aca26420558ba0a2fa78c2438d6a5101b"
-
-func makeSubmissionZip() ([]byte, error) {
-	err := os.Chdir("/autograder/submission")
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	// Make directories that would otherwise be Git submodules, but not included by Gradescope submission
-	err = os.MkdirAll("web/dist", 0777)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	_, err = git.PlainClone("/autograder/submission/app", false, &git.CloneOptions{
-		URL: "https://github.com/infracourse/yoctogram-app.git",
-	})
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	files, err := archiver.FilesFromDisk(nil, map[string]string{
-		".": "",
-	})
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	buf := &bytes.Buffer{}
-	format := archiver.Zip{}
-	err = format.Archive(context.TODO(), buf, files)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
-}
-
-type GradingLambdaRequest struct {
-	File []byte
-}
-
-func getCfnResources(gradingLambdaURI string, submissionZip []byte) (map[string]interface{}, error) {
-	request := GradingLambdaRequest{File: submissionZip}
-
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(request)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	resp, err := http.Post(gradingLambdaURI, "application/json", buf)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		log.Println("HTTP status code", resp.StatusCode)
-		return nil, fmt.Errorf("synthesizer lambda returned HTTP status code %d", resp.StatusCode)
-	}
-	defer resp.Body.Close()
-
-	var resources map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&resources)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	return resources, nil
-}
-
-func getOpaEvaluator() (func(r *rego.Rego), error) {
-	_, err := git.PlainClone("/grader", false, &git.CloneOptions{
-		URL: "https://github.com/infracourse/iac-grader.git",
-	})
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	return rego.LoadBundle("/grader/a4-rules"), nil
-}
-
-type GradescopeTest struct {
-	Score    float64 `json:"score"`
-	MaxScore float64 `json:"max_score"`
-	Name     string  `json:"name"`
-	Output   string  `json:"output"`
-}
-
-type ActionCheckOutput struct {
-	ActionsGrade int              `json:"actions_grade"`
-	Results      []GradescopeTest `json:"results"`
-}
-
-func doActionsCheck() (ActionCheckOutput, error) {
-	cmd := exec.Command("python3", "/autograder/action/grade.py", "/autograder/submission/main.yml")
-	if cmd.Err != nil {
-		log.Println(cmd.Err)
-		return ActionCheckOutput{}, cmd.Err
-	}
-
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-
-	if err := cmd.Run(); err != nil {
-		log.Println(err)
-		return ActionCheckOutput{}, err
-	}
-
-	var results ActionCheckOutput
-	err := json.Unmarshal(stdout.Bytes(), &results)
-	if err != nil {
-		log.Println(err)
-		return results, err
-	}
-
-	return results, nil
-}
-
-type GradescopeOutput struct {
-	Score float64          `json:"score"`
-	Tests []GradescopeTest `json:"tests"`
-}
-
 func main() {
-	submissionZip, err := makeSubmissionZip()
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	resources, err := getCfnResources(LAMBDA_GATEWAY_URI, submissionZip)
+	options, err := grader.ParseOptions(os.Args[1:])
 	if err != nil {
-		log.Println(err)
-		return
+		_ = grader.WriteFailure(options, err)
+		log.Fatalln(err)
 	}
 
-	evaluator, err := getOpaEvaluator()
+	manifest, err := grader.LoadManifest(options.ManifestPath)
 	if err != nil {
 		log.Println(err)
+		_ = grader.WriteFailure(options, err)
 		return
 	}
 
-	query, err := rego.New(
-		evaluator,
-		rego.Query("data.rules.main"),
-	).PrepareForEval(context.TODO())
+	err = grader.Run(grader.Assignment{
+		Manifest: manifest,
+		Rules:    rules.FS,
+		Checks: []grader.Check{
+			grader.PluginCheck("actions", 50.0, "python3", "/autograder/action/grade.py"),
+		},
+	}, options)
 	if err != nil {
 		log.Println(err)
-		return
-	}
-
-	results, err := query.Eval(context.TODO(), rego.EvalInput(resources))
-	if err != nil || len(results) == 0 {
-		log.Println(err)
-		return
 	}
-
-	failures := results[0].Expressions[0].Value.(map[string]interface{})["violations"].([]interface{})
-
-	actionsResults, err := doActionsCheck()
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	gradescopeFormattedOutput := GradescopeOutput{
-		Score: 150.0 - (10.0 * float64(len(failures))) - (50.0 - float64(actionsResults.ActionsGrade)),
-		Tests: make([]GradescopeTest, 0, len(failures)+len(actionsResults.Results)),
-	}
-
-	for _, failure := range failures {
-		gradescopeFormattedOutput.Tests = append(
-			gradescopeFormattedOutput.Tests,
-			GradescopeTest{
-				Score:    0,
-				MaxScore: 10.0,
-				Name:     fmt.Sprintf("%v", failure),
-			},
-		)
-	}
-
-	gradescopeFormattedOutput.Tests = append(gradescopeFormattedOutput.Tests, actionsResults.Results...)
-
-	output, err := json.MarshalIndent(gradescopeFormattedOutput, "", "  ")
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	_ = os.WriteFile("/autograder/results/results.json", output, 0777)
 }
@@ -0,0 +1,431 @@
+package grader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CASSETTE_VERSION is the version of the cassette format, which changes only
+// when old cassettes can no longer be replayed.
+const CASSETTE_VERSION = 1
+
+// CASSETTE_BODY_LIMIT bounds how much of each response body is recorded.
+const CASSETTE_BODY_LIMIT = 32 * 1024 * 1024
+
+// CASSETTE_EMBED_LIMIT is the largest response body that isn't JSON, such
+// as an image, that's embedded in results.json. Larger ones are embedded as
+// their digest and length, and kept whole only in the cassette's file.
+const CASSETTE_EMBED_LIMIT = 4 * 1024
+
+// REDACTED replaces secrets in what a cassette records.
+const REDACTED = "REDACTED"
+
+// REDACTED_PARAMS are the query parameters of signed URLs whose values are
+// redacted: CloudFront's, then S3's.
+var REDACTED_PARAMS = []string{"Signature", "Policy", "X-Amz-Signature", "X-Amz-Credential", "X-Amz-Security-Token"}
+
+// REDACTED_FIELDS are the JSON fields, such as tokens and the fields of
+// presigned S3 POSTs, whose values are redacted wherever they're nested.
+var REDACTED_FIELDS = []string{"access_token", "refresh_token", "token", "password", "policy", "signature", "x-amz-signature", "x-amz-credential", "x-amz-security-token", "AWSAccessKeyId"}
+
+// A Cassette is a recording of the HTTP exchanges a check made with the
+// student's deployment, so that the check can be re-scored later from the
+// recording rather than the deployment, which may be gone by then. It's
+// kept in results.json, less its large bodies (see Embedded), so everything
+// it records is redacted first: its tokens, passwords and URL signatures,
+// and any request body that isn't JSON, such as an upload's form.
+type Cassette struct {
+	Version    int       `json:"version"`
+	RecordedAt time.Time `json:"recorded_at"`
+
+	// Seed seeds whatever the check made up, such as account names, so that
+	// a replay makes the same requests.
+	Seed int64 `json:"seed"`
+
+	Interactions []Interaction `json:"interactions"`
+
+	mu sync.Mutex
+	// played marks the interactions already replayed.
+	played []bool
+}
+
+// An Interaction is one request and either its response or the error that
+// kept it from getting one.
+type Interaction struct {
+	Request  RecordedRequest   `json:"request"`
+	Response *RecordedResponse `json:"response,omitempty"`
+	Err      string            `json:"error,omitempty"`
+}
+
+// RecordedRequest leaves out the request's headers, which carry tokens.
+// URL and Body are redacted.
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   []byte `json:"body,omitempty"`
+}
+
+// RecordedResponse leaves out cookies, and its Location and body are
+// redacted. A body past CASSETTE_EMBED_LIMIT that isn't JSON also has its
+// SHA-256 and length, which are all of it that's embedded in results.json.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body,omitempty"`
+	BodyDigest string      `json:"body_sha256,omitempty"`
+	BodyLength int         `json:"body_length,omitempty"`
+}
+
+func NewCassette(seed int64) *Cassette {
+	return &Cassette{
+		Version:    CASSETTE_VERSION,
+		RecordedAt: time.Now().UTC(),
+		Seed:       seed,
+	}
+}
+
+func LoadCassette(path string) (*Cassette, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cassette := &Cassette{}
+	err = json.Unmarshal(contents, cassette)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cassette, cassette.check(path)
+}
+
+// LoadCassette loads the named check's cassette to replay it: from
+// ReplayResults if set, and otherwise from its file in CassetteDir.
+func (o Options) LoadCassette(check string) (*Cassette, error) {
+	if o.ReplayResults == "" {
+		path := o.CassettePath(check)
+		if path == "" {
+			return nil, errors.New("replaying needs the results.json or directory the cassettes were recorded to")
+		}
+		return LoadCassette(path)
+	}
+
+	var results struct {
+		ExtraData struct {
+			Cassettes map[string]*Cassette `json:"cassettes"`
+		} `json:"extra_data"`
+	}
+	err := o.readReplayResults(&results)
+	if err != nil {
+		return nil, err
+	}
+	cassette := results.ExtraData.Cassettes[check]
+	if cassette == nil {
+		return nil, fmt.Errorf("%s has no cassette for the %s check", o.ReplayResults, check)
+	}
+
+	// The bodies results.json has only digests of may still be in the
+	// cassette's file.
+	if path := o.CassettePath(check); path != "" {
+		if file, err := LoadCassette(path); err == nil {
+			cassette.restoreBodies(file)
+		}
+	}
+	return cassette, cassette.check(o.ReplayResults)
+}
+
+// LoadRecording decodes the named check's CheckResult.Recording from
+// ReplayResults into recording.
+func (o Options) LoadRecording(check string, recording interface{}) error {
+	var results struct {
+		ExtraData struct {
+			Recordings map[string]json.RawMessage `json:"recordings"`
+		} `json:"extra_data"`
+	}
+	err := o.readReplayResults(&results)
+	if err != nil {
+		return err
+	}
+	contents, ok := results.ExtraData.Recordings[check]
+	if !ok {
+		return fmt.Errorf("%s has no recording for the %s check", o.ReplayResults, check)
+	}
+	err = json.Unmarshal(contents, recording)
+	if err != nil {
+		return fmt.Errorf("%s: the %s check's recording: %w", o.ReplayResults, check, err)
+	}
+	return nil
+}
+
+func (o Options) readReplayResults(results interface{}) error {
+	contents, err := os.ReadFile(o.ReplayResults)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(contents, results)
+	if err != nil {
+		return fmt.Errorf("%s: %w", o.ReplayResults, err)
+	}
+	return nil
+}
+
+// Embedded is a copy of the cassette to embed in results.json, with only
+// the digests of the bodies too large to embed.
+func (c *Cassette) Embedded() *Cassette {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	embedded := &Cassette{Version: c.Version, RecordedAt: c.RecordedAt, Seed: c.Seed, Interactions: make([]Interaction, len(c.Interactions))}
+	for i, interaction := range c.Interactions {
+		if interaction.Response != nil && interaction.Response.BodyDigest != "" {
+			response := *interaction.Response
+			response.Body = nil
+			interaction.Response = &response
+		}
+		embedded.Interactions[i] = interaction
+	}
+	return embedded
+}
+
+// restoreBodies fills in the bodies the cassette has only digests of from
+// another recording of them, such as the cassette's file.
+func (c *Cassette) restoreBodies(from *Cassette) {
+	bodies := map[string][]byte{}
+	for _, interaction := range from.Interactions {
+		if interaction.Response != nil && interaction.Response.BodyDigest != "" && interaction.Response.Body != nil {
+			bodies[interaction.Response.BodyDigest] = interaction.Response.Body
+		}
+	}
+	for _, interaction := range c.Interactions {
+		if interaction.Response != nil && interaction.Response.Body == nil {
+			interaction.Response.Body = bodies[interaction.Response.BodyDigest]
+		}
+	}
+}
+
+func (c *Cassette) check(path string) error {
+	if c.Version != CASSETTE_VERSION {
+		return fmt.Errorf("%s: cassette version %d, not %d", path, c.Version, CASSETTE_VERSION)
+	}
+	return nil
+}
+
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	contents, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, contents, 0644)
+}
+
+// Recorder is a copy of client that records its exchanges to the cassette.
+func (c *Cassette) Recorder(client *http.Client) *http.Client {
+	inner := client.Transport
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	recorder := *client
+	recorder.Transport = &recordingTransport{cassette: c, inner: inner}
+	return &recorder
+}
+
+// Player is a client that answers requests from the cassette instead of
+// the network. Each request gets the response to the first interaction
+// with the same method and URL that hasn't been replayed yet, so a check
+// whose logic has changed can still be replayed as long as it makes the
+// same requests.
+func (c *Cassette) Player() *http.Client {
+	return &http.Client{Transport: &replayingTransport{cassette: c}}
+}
+
+type recordingTransport struct {
+	cassette *Cassette
+	inner    http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rawURL := req.URL.String()
+	interaction := Interaction{Request: RecordedRequest{Method: req.Method, URL: redactURL(rawURL)}}
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		contents, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+		interaction.Request.Body, _ = redactJSON(contents)
+	}
+
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		interaction.Err = strings.ReplaceAll(err.Error(), rawURL, interaction.Request.URL)
+		t.cassette.add(interaction)
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, CASSETTE_BODY_LIMIT))
+	resp.Body.Close()
+	if err != nil {
+		interaction.Err = err.Error()
+		t.cassette.add(interaction)
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	if location := header.Get("Location"); location != "" {
+		header.Set("Location", redactURL(location))
+	}
+	interaction.Response = &RecordedResponse{StatusCode: resp.StatusCode, Header: header, Body: body}
+	if redacted, ok := redactJSON(body); ok {
+		interaction.Response.Body = redacted
+		header.Del("Content-Length")
+	} else if len(body) > CASSETTE_EMBED_LIMIT {
+		digest := sha256.Sum256(body)
+		interaction.Response.BodyDigest = hex.EncodeToString(digest[:])
+		interaction.Response.BodyLength = len(body)
+	}
+	t.cassette.add(interaction)
+	return resp, nil
+}
+
+// redactURL redacts the signature of a signed URL. A URL without one is
+// left as it is.
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.RawQuery == "" {
+		return rawURL
+	}
+	query := parsed.Query()
+	redacted := false
+	for key := range query {
+		if matchesAny(key, REDACTED_PARAMS) {
+			query.Set(key, REDACTED)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return rawURL
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// redactJSON redacts the REDACTED_FIELDS and signed URLs in a JSON body.
+// It reports false, with a nil body, for a body that isn't JSON.
+func redactJSON(body []byte) ([]byte, bool) {
+	var value interface{}
+	if json.Unmarshal(body, &value) != nil {
+		return nil, false
+	}
+	redacted, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return nil, false
+	}
+	return redacted, true
+}
+
+func redactValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if matchesAny(key, REDACTED_FIELDS) {
+				value[key] = REDACTED
+			} else {
+				value[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, element := range value {
+			value[i] = redactValue(element)
+		}
+	case string:
+		if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+			return redactURL(value)
+		}
+	}
+	return value
+}
+
+func matchesAny(key string, names []string) bool {
+	for _, name := range names {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cassette) add(interaction Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, interaction)
+}
+
+type replayingTransport struct {
+	cassette *Cassette
+}
+
+func (t *replayingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	// The recording's URLs are redacted, so a request made from them, such
+	// as with a tampered-with signature, matches once it's redacted too.
+	interaction, ok := t.cassette.next(req.Method, redactURL(req.URL.String()))
+	if !ok {
+		return nil, fmt.Errorf("the cassette has no recorded response to %s %s", req.Method, req.URL.Redacted())
+	}
+	if interaction.Response == nil {
+		return nil, errors.New(interaction.Err)
+	}
+	if interaction.Response.Body == nil && interaction.Response.BodyDigest != "" {
+		return nil, fmt.Errorf("the cassette has only a digest of the %d-byte response to %s %s; replay with the cassettes directory it was recorded to for the whole response", interaction.Response.BodyLength, req.Method, req.URL.Redacted())
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        interaction.Response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (c *Cassette) next(method string, url string) (Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.played == nil {
+		c.played = make([]bool, len(c.Interactions))
+	}
+	for i, interaction := range c.Interactions {
+		if !c.played[i] && interaction.Request.Method == method && interaction.Request.URL == url {
+			c.played[i] = true
+			return interaction, true
+		}
+	}
+	return Interaction{}, false
+}
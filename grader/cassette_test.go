@@ -0,0 +1,196 @@
+package grader
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// SECRETS are what the test server hands out, none of which may end up in
+// a cassette.
+var SECRETS = []string{"eyJaccess", "refresh123", "hunter2", "policy64", "sig-abc", "ASIATEMP", "session-token", "cf-signature"}
+
+func secretServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Set-Cookie", "session=eyJaccess")
+			_, _ = w.Write([]byte(`{"access_token": "eyJaccess", "refresh_token": "refresh123"}`))
+		case "/upload":
+			_, _ = w.Write([]byte(`{"id": "1", "url": "https://bucket.s3.amazonaws.com/", "fields": {"key": "1.png", "policy": "policy64", "x-amz-signature": "sig-abc", "x-amz-credential": "ASIATEMP/20240101", "x-amz-security-token": "session-token"}}`))
+		case "/media":
+			_, _ = w.Write([]byte(`{"uri": "https://cdn.example.com/1.png?Expires=1&Signature=cf-signature&Key-Pair-Id=K1"}`))
+		case "/photo":
+			_, _ = w.Write(bytes.Repeat([]byte{0xff, 0xd8}, CASSETTE_EMBED_LIMIT))
+		case "/image":
+			if r.URL.Query().Get("Signature") != "cf-signature" {
+				w.WriteHeader(http.StatusForbidden)
+			}
+			_, _ = w.Write([]byte("\x89PNG"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func get(t *testing.T, client *http.Client, url string) (int, []byte) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestCassetteRedactsSecrets(t *testing.T) {
+	server := secretServer(t)
+	cassette := NewCassette(1)
+	client := cassette.Recorder(server.Client())
+
+	resp, err := client.Post(server.URL+"/login", "application/json", strings.NewReader(`{"username": "grader1", "password": "hunter2"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Contains(body, []byte("eyJaccess")) {
+		t.Errorf("the client was given a redacted response: %s", body)
+	}
+	resp, err = client.Post(server.URL+"/s3", "multipart/form-data; boundary=x", strings.NewReader("--x\r\nContent-Disposition: form-data; name=\"x-amz-signature\"\r\n\r\nsig-abc\r\n--x--\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	get(t, client, server.URL+"/upload")
+	get(t, client, server.URL+"/media")
+	get(t, client, server.URL+"/image?Expires=1&Signature=cf-signature&Key-Pair-Id=K1")
+
+	// Bodies are encoded as base64, so they're searched as they're
+	// recorded instead.
+	encoded, err := json.Marshal(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := append([]byte(nil), encoded...)
+	for _, interaction := range cassette.Interactions {
+		recorded = append(recorded, interaction.Request.Body...)
+		if interaction.Response != nil {
+			recorded = append(recorded, interaction.Response.Body...)
+		}
+	}
+	for _, secret := range SECRETS {
+		if bytes.Contains(recorded, []byte(secret)) {
+			t.Errorf("the cassette records %q: %s", secret, recorded)
+		}
+	}
+	if !bytes.Contains(recorded, []byte(`"username":"grader1"`)) || !bytes.Contains(recorded, []byte("Key-Pair-Id=K1")) {
+		t.Errorf("the cassette redacts more than secrets: %s", recorded)
+	}
+}
+
+func TestCassetteReplaysRedactedSignatures(t *testing.T) {
+	server := secretServer(t)
+	cassette := NewCassette(1)
+	client := cassette.Recorder(server.Client())
+	get(t, client, server.URL+"/image?Expires=1&Signature=cf-signature&Key-Pair-Id=K1")
+	get(t, client, server.URL+"/image?Expires=1&Signature=cf-sigXature&Key-Pair-Id=K1")
+
+	// Replayed, the link comes from a redacted response, and the tampered
+	// one is made from it.
+	player := cassette.Player()
+	status, body := get(t, player, server.URL+"/image?Expires=1&Key-Pair-Id=K1&Signature=REDACTED")
+	if status != http.StatusOK || string(body) != "\x89PNG" {
+		t.Errorf("replaying the signed link got %d %q", status, body)
+	}
+	status, _ = get(t, player, server.URL+"/image?Expires=1&Key-Pair-Id=K1&Signature=REDAXTED")
+	if status != http.StatusForbidden {
+		t.Errorf("replaying the tampered-with link got %d, want 403", status)
+	}
+}
+
+func TestCassetteReplaysFromResults(t *testing.T) {
+	server := secretServer(t)
+	cassette := NewCassette(42)
+	get(t, cassette.Recorder(server.Client()), server.URL+"/media")
+
+	path := filepath.Join(t.TempDir(), "results.json")
+	err := writeResults(path, GradescopeOutput{ExtraData: map[string]interface{}{"cassettes": map[string]*Cassette{"runtime": cassette}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := Options{Replay: true, ReplayResults: path}
+	replayed, err := options.LoadCassette("runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Seed != 42 {
+		t.Errorf("replayed seed %d, want 42", replayed.Seed)
+	}
+	status, body := get(t, replayed.Player(), server.URL+"/media")
+	if status != http.StatusOK || !bytes.Contains(body, []byte("Signature=REDACTED")) {
+		t.Errorf("replaying got %d %s", status, body)
+	}
+
+	_, err = options.LoadCassette("compression")
+	if err == nil {
+		t.Error("loaded a cassette results.json doesn't have")
+	}
+}
+
+func TestResultsEmbedOnlyDigestsOfLargeBodies(t *testing.T) {
+	server := secretServer(t)
+	cassette := NewCassette(1)
+	_, photo := get(t, cassette.Recorder(server.Client()), server.URL+"/photo")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.json")
+	err := writeResults(path, GradescopeOutput{ExtraData: map[string]interface{}{"cassettes": map[string]*Cassette{"runtime": cassette.Embedded()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embedded) > CASSETTE_EMBED_LIMIT {
+		t.Errorf("results.json is %d bytes, embedding the %d-byte photo", len(embedded), len(photo))
+	}
+
+	// Replayed from results.json alone, the photo is an error saying so.
+	options := Options{Replay: true, ReplayResults: path}
+	replayed, err := options.LoadCassette("runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = replayed.Player().Get(server.URL + "/photo")
+	if err == nil || !strings.Contains(err.Error(), "only a digest") {
+		t.Errorf("replayed the photo without its body: %v", err)
+	}
+
+	// With the cassette's file alongside, the photo comes from it.
+	options.CassetteDir = dir
+	err = cassette.Save(options.CassettePath("runtime"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err = options.LoadCassette("runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, body := get(t, replayed.Player(), server.URL+"/photo")
+	if status != http.StatusOK || !bytes.Equal(body, photo) {
+		t.Errorf("replaying got %d and %d bytes, want %d", status, len(body), len(photo))
+	}
+}
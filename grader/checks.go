@@ -0,0 +1,134 @@
+package grader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// A Check is an auxiliary grading step run alongside the rule evaluation,
+// such as probing the student's deployment or validating a submitted flag.
+// Points it does not award are deducted from the assignment total.
+//
+// Checks run concurrently with the rest of grading. Run must give up once
+// ctx is done; the check is failed then anyway.
+type Check struct {
+	Name     string
+	MaxScore float64
+	Run      func(ctx context.Context, submission Submission) (CheckResult, error)
+
+	// Timeout bounds the check's run time, overriding Options.CheckTimeout.
+	Timeout time.Duration
+}
+
+type CheckResult struct {
+	Score float64
+	Tests []GradescopeTest
+
+	// Cassette is what the check recorded of the deployment, if anything,
+	// which is kept in results.json for it to be replayed.
+	Cassette *Cassette
+
+	// Recording is whatever else the check judged that it can be judged
+	// again from, such as samples, which is kept in results.json as JSON for
+	// Options.LoadRecording. Keep it small.
+	Recording interface{}
+}
+
+// scale reweights a result out of maxScore points to one out of points,
+// scaling its tests to match.
+func (r CheckResult) scale(maxScore, points float64) CheckResult {
+	if maxScore == points || maxScore == 0 {
+		return r
+	}
+
+	factor := points / maxScore
+	scaled := CheckResult{
+		Score:     r.Score * factor,
+		Tests:     make([]GradescopeTest, 0, len(r.Tests)),
+		Cassette:  r.Cassette,
+		Recording: r.Recording,
+	}
+	for _, test := range r.Tests {
+		test.Score *= factor
+		test.MaxScore *= factor
+		scaled.Tests = append(scaled.Tests, test)
+	}
+	return scaled
+}
+
+// runCheck runs check, converting an error, panic or timeout into a
+// zero-score result so that one broken check doesn't prevent the rest of the
+// submission from being graded. A check that ignores its context is
+// abandoned when it times out rather than waited for.
+func runCheck(ctx context.Context, check Check, submission Submission, timeout time.Duration) CheckResult {
+	if check.Timeout != 0 {
+		timeout = check.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var result CheckResult
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("panic in %s check: %v\n%s", check.Name, r, debug.Stack())
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		result, err = check.Run(ctx, submission)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("%s check: %v", check.Name, ctx.Err())
+		return checkFailure(check, fmt.Errorf("timed out (%w)", ctx.Err()))
+	}
+
+	if err != nil {
+		log.Println(err)
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out (%w)", err)
+		}
+		return checkFailure(check, err)
+	}
+
+	return result
+}
+
+// checkSkipped awards a check's points without running it, so that
+// skipping checks, such as when grading locally, doesn't deduct them.
+func checkSkipped(check Check) CheckResult {
+	return CheckResult{
+		Score: check.MaxScore,
+		Tests: []GradescopeTest{
+			{
+				Score:    0,
+				MaxScore: 0,
+				Name:     fmt.Sprintf("Run %s checks", check.Name),
+				Output:   fmt.Sprintf("The %s checks were skipped, and their %v points were awarded without running them.", check.Name, check.MaxScore),
+			},
+		},
+	}
+}
+
+func checkFailure(check Check, err error) CheckResult {
+	return CheckResult{
+		Score: 0,
+		Tests: []GradescopeTest{
+			{
+				Score:    0,
+				MaxScore: check.MaxScore,
+				Name:     fmt.Sprintf("Run %s checks", check.Name),
+				Output:   fmt.Sprintf("The %s checks failed to run: %v\n\nThis may be a problem with the autograder rather than your submission; contact course staff if it persists.", check.Name, err),
+			},
+		},
+	}
+}
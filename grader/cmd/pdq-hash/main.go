@@ -0,0 +1,39 @@
+// Command pdq-hash prints the PDQ hash and quality of screenshots, such as
+// to add a reference rendering of the front page:
+//
+//	go run ./cmd/pdq-hash screenshot.png
+//
+// Screenshots a check recorded can be hashed the same way.
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"os"
+
+	"infracourse.cloud/a2-grader/grader/perceptual"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalln("usage: pdq-hash <image>...")
+	}
+
+	for _, path := range os.Args[1:] {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+
+		hash, quality := perceptual.PDQ(img)
+		fmt.Printf("%s %d %s\n", hash, quality, path)
+	}
+}
@@ -0,0 +1,108 @@
+// Command sign-rules signs rule bundles for the orchestrators, which refuse
+// to grade with a bundle that isn't signed with the course's key. Run it
+// after changing any file in a bundle:
+//
+//	go run ./cmd/sign-rules -key rules.key ../rules/a2-rules ../rules/a3-rules
+//
+// Each bundle's signature is written to .signatures.json in its directory.
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/open-policy-agent/opa/bundle"
+	"github.com/open-policy-agent/opa/util"
+	"infracourse.cloud/a2-grader/grader"
+)
+
+const SIGNATURES_FILE = ".signatures.json"
+
+func main() {
+	keyPath := flag.String("key", "", "PEM-encoded RSA private key to sign with")
+	flag.Parse()
+	if *keyPath == "" || flag.NArg() == 0 {
+		log.Fatalln("usage: sign-rules -key <private key> <bundle directory>...")
+	}
+
+	key, err := os.ReadFile(*keyPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	for _, dir := range flag.Args() {
+		err = signBundle(dir, string(key))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("signed", dir)
+	}
+}
+
+func signBundle(dir string, key string) error {
+	hasher, err := bundle.NewSignatureHasher(bundle.SHA256)
+	if err != nil {
+		return err
+	}
+
+	// Every file but the signature itself is signed, so the orchestrator can
+	// tell if one has been added or removed as well as changed.
+	files := []bundle.FileInfo{}
+	fsys := os.DirFS(dir)
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path == SIGNATURES_FILE {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%s: not a regular file", filepath.Join(dir, path))
+		}
+
+		contents, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+
+		// OPA hashes data files and the manifest by their parsed value,
+		// so formatting doesn't change their signature.
+		var value interface{} = contents
+		if bundle.IsStructuredDoc(path) {
+			err = util.Unmarshal(contents, &value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filepath.Join(dir, path), err)
+			}
+		}
+
+		hash, err := hasher.HashFile(value)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, bundle.NewFile(path, hex.EncodeToString(hash), string(bundle.SHA256)))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New(dir + ": rule bundle is empty")
+	}
+
+	token, err := bundle.GenerateSignedToken(files, bundle.NewSigningConfig(key, "RS256", ""), grader.RULES_KEY_ID)
+	if err != nil {
+		return err
+	}
+
+	signatures, err := json.MarshalIndent(map[string][]string{"signatures": {token}}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, SIGNATURES_FILE), append(signatures, '\n'), 0644)
+}
@@ -0,0 +1,192 @@
+// Package compression judges how a deployment compressed an image uploaded
+// to it, by measuring the image it serves against the original: its format,
+// its size in bytes and in pixels, and how much of the original it still
+// looks like.
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"math"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"infracourse.cloud/a2-grader/grader/perceptual"
+)
+
+// Defaults for a rubric that leaves them out.
+var (
+	DEFAULT_FORMATS = []string{"jpeg", "png"}
+	DEFAULT_RATIO   = Band{Max: 0.75}
+	DEFAULT_SCALE   = Band{Min: 0.25, Max: 1}
+	DEFAULT_SSIM    = Band{Min: 0.8}
+)
+
+const DEFAULT_ASPECT_TOLERANCE = 0.02
+
+// A Band is a range a measurement must fall in. A zero bound is no bound.
+type Band struct {
+	Min float64 `json:"min,omitempty"`
+	Max float64 `json:"max,omitempty"`
+}
+
+func (b Band) Contains(value float64) bool {
+	return (b.Min == 0 || value >= b.Min) && (b.Max == 0 || value <= b.Max)
+}
+
+func (b Band) String() string {
+	switch {
+	case b.Min != 0 && b.Max != 0:
+		return fmt.Sprintf("between %.2f and %.2f", b.Min, b.Max)
+	case b.Min != 0:
+		return fmt.Sprintf("at least %.2f", b.Min)
+	case b.Max != 0:
+		return fmt.Sprintf("at most %.2f", b.Max)
+	}
+	return "anything"
+}
+
+// A Rubric is what counts as compressing an image well.
+type Rubric struct {
+	// Formats are the formats the image may be served in, as Go's image
+	// package names them: jpeg, png or gif.
+	Formats []string `json:"formats"`
+
+	// Ratio bounds the served image's size in bytes over the upload's.
+	Ratio Band `json:"ratio"`
+
+	// Scale bounds the served image's width over the upload's, and
+	// AspectTolerance how far its aspect ratio may stray from the upload's,
+	// as a fraction of it.
+	Scale           Band    `json:"scale"`
+	AspectTolerance float64 `json:"aspect_tolerance"`
+
+	// SSIM bounds how alike the served image and the upload look.
+	SSIM Band `json:"ssim"`
+}
+
+func DefaultRubric() Rubric {
+	return Rubric{
+		Formats:         DEFAULT_FORMATS,
+		Ratio:           DEFAULT_RATIO,
+		Scale:           DEFAULT_SCALE,
+		AspectTolerance: DEFAULT_ASPECT_TOLERANCE,
+		SSIM:            DEFAULT_SSIM,
+	}
+}
+
+// LoadRubric reads a rubric, filling in the defaults for whatever it leaves
+// out.
+func LoadRubric(path string) (Rubric, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		log.Println(err)
+		return Rubric{}, err
+	}
+
+	rubric := DefaultRubric()
+	err = yaml.UnmarshalStrict(contents, &rubric)
+	if err != nil {
+		log.Println(err)
+		return Rubric{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(rubric.Formats) == 0 {
+		return Rubric{}, fmt.Errorf("%s: no formats", path)
+	}
+	return rubric, nil
+}
+
+// A Measurement compares a served image with the original upload.
+type Measurement struct {
+	Format         string
+	OriginalFormat string
+
+	Bytes         int
+	OriginalBytes int
+
+	Width          int
+	Height         int
+	OriginalWidth  int
+	OriginalHeight int
+
+	SSIM float64
+}
+
+// Measure compares the image served with the original uploaded.
+func Measure(original []byte, served []byte) (Measurement, error) {
+	before, originalFormat, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return Measurement{}, fmt.Errorf("malformed original image: %w", err)
+	}
+	after, format, err := image.Decode(bytes.NewReader(served))
+	if err != nil {
+		return Measurement{}, fmt.Errorf("The image served couldn't be read as a JPEG, PNG or GIF: %v", err)
+	}
+
+	return Measurement{
+		Format:         format,
+		OriginalFormat: originalFormat,
+		Bytes:          len(served),
+		OriginalBytes:  len(original),
+		Width:          after.Bounds().Dx(),
+		Height:         after.Bounds().Dy(),
+		OriginalWidth:  before.Bounds().Dx(),
+		OriginalHeight: before.Bounds().Dy(),
+		SSIM:           perceptual.SSIM(before, after),
+	}, nil
+}
+
+// Ratio is the served image's size in bytes over the original's.
+func (m Measurement) Ratio() float64 {
+	return float64(m.Bytes) / float64(m.OriginalBytes)
+}
+
+// Scale is the served image's width over the original's.
+func (m Measurement) Scale() float64 {
+	return float64(m.Width) / float64(m.OriginalWidth)
+}
+
+// CheckFormat and the rubric's other criteria check a measurement,
+// explaining to the student how it falls short.
+func (r Rubric) CheckFormat(m Measurement) error {
+	for _, format := range r.Formats {
+		if strings.EqualFold(format, m.Format) {
+			return nil
+		}
+	}
+	return fmt.Errorf("The image was served as a %s, but should be one of: %s", strings.ToUpper(m.Format), strings.ToUpper(strings.Join(r.Formats, ", ")))
+}
+
+func (r Rubric) CheckRatio(m Measurement) error {
+	if r.Ratio.Contains(m.Ratio()) {
+		return nil
+	}
+	return fmt.Errorf("The image served is %d bytes, %.2f times the %d bytes uploaded, but should be %s times", m.Bytes, m.Ratio(), m.OriginalBytes, r.Ratio)
+}
+
+func (r Rubric) CheckDimensions(m Measurement) error {
+	if !r.Scale.Contains(m.Scale()) {
+		return fmt.Errorf("The image served is %dx%d, %.2f times the width of the %dx%d upload, but should be %s times", m.Width, m.Height, m.Scale(), m.OriginalWidth, m.OriginalHeight, r.Scale)
+	}
+
+	aspect := float64(m.Width) / float64(m.Height)
+	originalAspect := float64(m.OriginalWidth) / float64(m.OriginalHeight)
+	if math.Abs(aspect-originalAspect) > r.AspectTolerance*originalAspect {
+		return fmt.Errorf("The image served is %dx%d, which distorts the %dx%d upload", m.Width, m.Height, m.OriginalWidth, m.OriginalHeight)
+	}
+	return nil
+}
+
+func (r Rubric) CheckQuality(m Measurement) error {
+	if r.SSIM.Contains(m.SSIM) {
+		return nil
+	}
+	return fmt.Errorf("The image served has a structural similarity (SSIM) of %.2f to the upload, but should have %s", m.SSIM, r.SSIM)
+}
@@ -0,0 +1,172 @@
+package compression
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// photo is a w by h image with enough detail for compressing it to cost
+// something: a gradient crossed by stripes.
+func photo(w int, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			stripe := uint8(0)
+			if (x/7+y/11)%2 == 0 {
+				stripe = 80
+			}
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: stripe, A: 255})
+		}
+	}
+	return img
+}
+
+// shrunk is img scaled to w by h by nearest neighbour.
+func shrunk(img image.Image, w int, h int) image.Image {
+	bounds := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.Set(x, y, img.At(x*bounds.Dx()/w, y*bounds.Dy()/h))
+		}
+	}
+	return out
+}
+
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	err := jpeg.Encode(buf, img, &jpeg.Options{Quality: quality})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// judge measures served against original and returns which of the
+// default rubric's criteria it fails.
+func judge(t *testing.T, original []byte, served []byte) map[string]bool {
+	t.Helper()
+	m, err := Measure(original, served)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rubric := DefaultRubric()
+	failed := map[string]bool{}
+	for name, check := range map[string]func(Measurement) error{
+		"format":     rubric.CheckFormat,
+		"ratio":      rubric.CheckRatio,
+		"dimensions": rubric.CheckDimensions,
+		"quality":    rubric.CheckQuality,
+	} {
+		if check(m) != nil {
+			failed[name] = true
+		}
+	}
+	return failed
+}
+
+func expectFailures(t *testing.T, failed map[string]bool, want ...string) {
+	t.Helper()
+	if len(failed) != len(want) {
+		t.Errorf("failed %v, want %v", failed, want)
+		return
+	}
+	for _, name := range want {
+		if !failed[name] {
+			t.Errorf("failed %v, want %v", failed, want)
+		}
+	}
+}
+
+func TestReencodedImagePasses(t *testing.T) {
+	original := encodeJPEG(t, photo(500, 500), 95)
+	expectFailures(t, judge(t, original, encodeJPEG(t, photo(500, 500), 40)))
+}
+
+func TestDownscaledImagePasses(t *testing.T) {
+	original := encodeJPEG(t, photo(500, 500), 95)
+	expectFailures(t, judge(t, original, encodeJPEG(t, shrunk(photo(500, 500), 250, 250), 75)))
+}
+
+func TestUntouchedImageFailsRatio(t *testing.T) {
+	original := encodeJPEG(t, photo(500, 500), 95)
+	expectFailures(t, judge(t, original, original), "ratio")
+}
+
+func TestFlatImageFailsQuality(t *testing.T) {
+	original := encodeJPEG(t, photo(500, 500), 95)
+	flat := image.NewGray(image.Rect(0, 0, 500, 500))
+	for i := range flat.Pix {
+		flat.Pix[i] = 128
+	}
+	expectFailures(t, judge(t, original, encodeJPEG(t, flat, 75)), "quality")
+}
+
+func TestDistortedImageFailsDimensions(t *testing.T) {
+	original := encodeJPEG(t, photo(500, 500), 95)
+	failed := judge(t, original, encodeJPEG(t, shrunk(photo(500, 500), 400, 200), 75))
+	if !failed["dimensions"] {
+		t.Errorf("failed %v, want dimensions among them", failed)
+	}
+}
+
+func TestPNGFailsJPEGOnlyRubric(t *testing.T) {
+	original := encodeJPEG(t, photo(100, 100), 95)
+	m, err := Measure(original, encodePNG(t, shrunk(photo(100, 100), 50, 50)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rubric := DefaultRubric()
+	rubric.Formats = []string{"jpeg"}
+	if rubric.CheckFormat(m) == nil {
+		t.Errorf("a %s passed a JPEG-only rubric", m.Format)
+	}
+}
+
+func TestUnreadableImageFailsMeasuring(t *testing.T) {
+	original := encodeJPEG(t, photo(100, 100), 95)
+	_, err := Measure(original, []byte("RIFF....WEBPVP8 "))
+	if err == nil {
+		t.Error("measured an image in a format that can't be read")
+	}
+}
+
+func TestLoadRubricFillsInDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compression.yaml")
+	err := os.WriteFile(path, []byte("ratio:\n  max: 0.5\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rubric, err := LoadRubric(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rubric.Ratio.Max != 0.5 || rubric.SSIM != DEFAULT_SSIM || len(rubric.Formats) != len(DEFAULT_FORMATS) {
+		t.Errorf("loaded %+v", rubric)
+	}
+
+	err = os.WriteFile(path, []byte("formats: []\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRubric(path); err == nil {
+		t.Error("loaded a rubric without formats")
+	}
+}
@@ -0,0 +1,71 @@
+package grader
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// A StageError records which step of the pipeline failed, so the student can
+// be told whether to fix their submission or to contact course staff.
+type StageError struct {
+	Stage string
+	Err   error
+
+	// StudentFault is set when the failure was caused by the submission
+	// itself rather than by the autograder.
+	StudentFault bool
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// A failed pipeline scores zero: there is nothing to grade without a
+// synthesized template and rule results. Failures of individual checks don't
+// end up here; they only forfeit that check's points (see runCheck).
+func failedResults(err error) GradescopeOutput {
+	stage := "grading"
+	advice := "This is a problem with the autograder, not your submission. Please resubmit, and contact course staff if it keeps happening."
+
+	var stageErr *StageError
+	if errors.As(err, &stageErr) {
+		stage = stageErr.Stage
+		if stageErr.StudentFault {
+			advice = "Please fix the error below and resubmit."
+		}
+	}
+
+	return GradescopeOutput{
+		Score:  0,
+		Output: fmt.Sprintf("The autograder failed while %s. %s", stage, advice),
+		Tests: []GradescopeTest{
+			{
+				Score:    0,
+				MaxScore: 0,
+				Name:     "Autograder failed while " + stage,
+				Output:   err.Error(),
+			},
+		},
+	}
+}
+
+// WriteFailure writes a results file for a submission that couldn't be
+// graded at all, e.g. because the assignment manifest failed to load.
+func WriteFailure(options Options, err error) error {
+	return writeResults(options.ResultsPath, failedResults(err))
+}
+
+// recoverStage converts a panic into a StageError for the stage that was
+// running, logging the stack trace for staff.
+func recoverStage(stage *string, err *error) {
+	if r := recover(); r != nil {
+		log.Printf("panic while %s: %v\n%s", *stage, r, debug.Stack())
+		*err = &StageError{Stage: *stage, Err: fmt.Errorf("panic: %v", r)}
+	}
+}
@@ -0,0 +1,397 @@
+// Package frontpage checks that a student's deployment serves the Yoctogram
+// front page, by rendering it in headless Chrome and comparing screenshots
+// with reference renderings by their PDQ hashes.
+package frontpage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"infracourse.cloud/a2-grader/grader"
+	"infracourse.cloud/a2-grader/grader/perceptual"
+	"infracourse.cloud/a2-grader/grader/yoctogram"
+)
+
+// Defaults for a config that leaves them out. DEFAULT_THRESHOLD is out of
+// perceptual.HASH_BITS.
+const (
+	DEFAULT_CHROMEDRIVER  = "chromedriver"
+	DEFAULT_WIDTH         = 1920
+	DEFAULT_HEIGHT        = 1080
+	DEFAULT_THRESHOLD     = 16
+	DEFAULT_TIMEOUT       = time.Minute
+	DEFAULT_POLL_INTERVAL = time.Second
+)
+
+// STABLE_POLLS is how many polls in a row a loaded page must look the same
+// for before it's taken to have finished rendering.
+const STABLE_POLLS = 3
+
+// HEATMAP_WIDTH is how wide the heatmap shown to students is.
+const HEATMAP_WIDTH = 640
+
+// MIN_QUALITY is the PDQ quality below which a screenshot is too
+// featureless, such as a blank page, for its hash to mean much.
+const MIN_QUALITY = 50
+
+// A Config lists an assignment's reference renderings of the front page and
+// how screenshots are compared with them.
+type Config struct {
+	Chromedriver string `json:"chromedriver,omitempty"`
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+
+	// Threshold is how many bits a screenshot's hash may differ from a
+	// reference's in and still match it, unless the reference sets its own.
+	Threshold int `json:"threshold,omitempty"`
+
+	// Timeout bounds how long the page has to render after loading, and
+	// PollInterval is how often it's checked in the meantime.
+	Timeout      grader.Duration `json:"timeout,omitempty"`
+	PollInterval grader.Duration `json:"poll_interval,omitempty"`
+
+	References []ReferenceConfig `json:"references"`
+
+	references []perceptual.Reference
+}
+
+type ReferenceConfig struct {
+	Name string `json:"name"`
+
+	// Hash is the reference's PDQ hash in hex.
+	Hash string `json:"hash"`
+
+	Threshold *int `json:"threshold,omitempty"`
+
+	// Image is a PNG of the reference rendering, relative to the config
+	// file, from which heatmaps are drawn. It's optional.
+	Image string `json:"image,omitempty"`
+}
+
+func LoadConfig(path string) (Config, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		log.Println(err)
+		return Config{}, err
+	}
+
+	config := Config{
+		Chromedriver: DEFAULT_CHROMEDRIVER,
+		Width:        DEFAULT_WIDTH,
+		Height:       DEFAULT_HEIGHT,
+		Threshold:    DEFAULT_THRESHOLD,
+		Timeout:      grader.Duration(DEFAULT_TIMEOUT),
+		PollInterval: grader.Duration(DEFAULT_POLL_INTERVAL),
+	}
+	err = yaml.UnmarshalStrict(contents, &config)
+	if err != nil {
+		log.Println(err)
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(config.References) == 0 {
+		return Config{}, fmt.Errorf("%s: no references", path)
+	}
+
+	for _, ref := range config.References {
+		reference := perceptual.Reference{Name: ref.Name, Threshold: config.Threshold}
+		reference.Hash, err = perceptual.ParseHash(ref.Hash)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: reference %q: %w", path, ref.Name, err)
+		}
+		if ref.Threshold != nil {
+			reference.Threshold = *ref.Threshold
+		}
+		if ref.Image != "" {
+			reference.Image, err = readPNG(filepath.Join(filepath.Dir(path), ref.Image))
+			if err != nil {
+				return Config{}, fmt.Errorf("%s: reference %q: %w", path, ref.Name, err)
+			}
+		}
+		config.references = append(config.references, reference)
+	}
+	return config, nil
+}
+
+// A Rendering is the screenshot the page was judged by.
+type Rendering struct {
+	PNG     []byte
+	Image   image.Image
+	Hash    perceptual.Hash
+	Quality int
+}
+
+// A RenderingRecord is what of a rendering is kept in results.json to judge
+// it again by: its hash, without the screenshot, which is kept in the
+// cassettes directory instead.
+type RenderingRecord struct {
+	Hash    string `json:"hash"`
+	Quality int    `json:"quality"`
+}
+
+func (r Rendering) record() RenderingRecord {
+	return RenderingRecord{Hash: r.Hash.String(), Quality: r.Quality}
+}
+
+func newRendering(screenshot []byte) (Rendering, error) {
+	img, err := png.Decode(bytes.NewReader(screenshot))
+	if err != nil {
+		return Rendering{}, fmt.Errorf("malformed screenshot: %w", err)
+	}
+	hash, quality := perceptual.PDQ(img)
+	return Rendering{PNG: screenshot, Image: img, Hash: hash, Quality: quality}, nil
+}
+
+// A LoadError means the page couldn't be loaded at all, which is the
+// deployment's fault rather than the grader's.
+type LoadError struct {
+	Err error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("The front page failed to load: %v", e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// Render loads url and polls screenshots of it until one matches a
+// reference, or the page has loaded and stopped changing, or the config's
+// timeout runs out. It returns the last screenshot.
+func (c Config) Render(ctx context.Context, url string) (Rendering, error) {
+	browser, err := StartBrowser(ctx, c.Chromedriver, c.Width, c.Height)
+	if err != nil {
+		return Rendering{}, err
+	}
+	defer browser.Close()
+
+	err = browser.Navigate(ctx, url)
+	var driverErr *WebDriverError
+	if errors.As(err, &driverErr) {
+		return Rendering{}, &LoadError{Err: errors.New(driverErr.Message)}
+	} else if err != nil {
+		log.Println(err)
+		return Rendering{}, err
+	}
+
+	pollCtx, cancel := context.WithTimeout(ctx, time.Duration(c.Timeout))
+	defer cancel()
+
+	var rendering Rendering
+	stable := 0
+	for polls := 0; ; polls++ {
+		state, err := browser.ReadyState(pollCtx)
+		if err != nil && pollCtx.Err() == nil {
+			log.Println(err)
+			return Rendering{}, err
+		}
+		screenshot, err := browser.Screenshot(pollCtx)
+		if err != nil && pollCtx.Err() == nil {
+			log.Println(err)
+			return Rendering{}, err
+		}
+		if pollCtx.Err() != nil {
+			break
+		}
+
+		last := rendering
+		rendering, err = newRendering(screenshot)
+		if err != nil {
+			log.Println(err)
+			return Rendering{}, err
+		}
+		match, _ := perceptual.Nearest(rendering.Hash, c.references)
+		log.Printf("frontpage: poll %d: document %s, %d bits from %q", polls, state, match.Distance, match.Reference.Name)
+		if match.Matched() {
+			return rendering, nil
+		}
+
+		if state == "complete" && polls > 0 && rendering.Hash == last.Hash {
+			stable++
+		} else {
+			stable = 0
+		}
+		if stable >= STABLE_POLLS {
+			return rendering, nil
+		}
+
+		select {
+		case <-pollCtx.Done():
+		case <-time.After(time.Duration(c.PollInterval)):
+		}
+	}
+
+	if rendering.PNG == nil {
+		return Rendering{}, &LoadError{Err: fmt.Errorf("the page didn't render within %v", c.Timeout)}
+	}
+	log.Printf("frontpage: the page was still changing after %v", c.Timeout)
+	return rendering, nil
+}
+
+// Judge compares a rendering with the config's references, as a test worth
+// points.
+func (c Config) Judge(rendering Rendering, points float64) (grader.GradescopeTest, *image.RGBA) {
+	test := grader.GradescopeTest{Name: "Validate front page loads", MaxScore: points}
+
+	match, _ := perceptual.Nearest(rendering.Hash, c.references)
+	log.Printf("frontpage: hash %s, quality %d, %d bits from %q", rendering.Hash, rendering.Quality, match.Distance, match.Reference.Name)
+	if match.Matched() {
+		test.Score = points
+		test.Output = "Pass"
+		return test, nil
+	}
+
+	message := fmt.Sprintf("The front page doesn't look as expected: its screenshot differs from the closest reference rendering in %d of %d bits of its perceptual hash, and at most %d may differ.", match.Distance, perceptual.HASH_BITS, match.Reference.Threshold)
+	if rendering.Quality < MIN_QUALITY {
+		message += " The page looks mostly blank."
+	}
+	if match.Reference.Image == nil || rendering.Image == nil {
+		test.Output = message
+		return test, nil
+	}
+
+	heatmap := perceptual.Heatmap(rendering.Image, match.Reference.Image)
+	encoded, err := encodePNG(perceptual.Shrink(heatmap, HEATMAP_WIDTH))
+	if err != nil {
+		log.Println(err)
+		test.Output = message
+		return test, heatmap
+	}
+	test.OutputFormat = "html"
+	test.Output = fmt.Sprintf(`<p>%s Red marks where it differs most from the reference:</p><img alt="Difference heatmap" src="data:image/png;base64,%s">`,
+		html.EscapeString(message), base64.StdEncoding.EncodeToString(encoded))
+	return test, heatmap
+}
+
+// Check renders the submitter's front page and compares it with the
+// references in options.FrontpageConfigPath, worth points. The page is at
+// options.YoctogramURL if set, and otherwise at the URL for the SUNet ID in
+// the submission's SUNET file.
+//
+// The screenshot is recorded alongside the check's other recordings, and
+// its hash in results.json. With options.Replay it's judged again rather
+// than taken afresh: the screenshot if it was recorded, and otherwise the
+// hash in options.ReplayResults, without a heatmap.
+func Check(name string, points float64, options grader.Options) grader.Check {
+	return grader.Check{
+		Name:     name,
+		MaxScore: points,
+		Run: func(ctx context.Context, submission grader.Submission) (grader.CheckResult, error) {
+			config, err := LoadConfig(options.FrontpageConfigPath)
+			if err != nil {
+				return grader.CheckResult{}, err
+			}
+
+			screenshotPath := options.RecordingPath(name, ".png")
+			var rendering Rendering
+			if options.Replay {
+				rendering, err = replayRendering(name, screenshotPath, options)
+				if err != nil {
+					return grader.CheckResult{}, err
+				}
+			} else {
+				url := options.YoctogramURL
+				if url == "" {
+					sunet, err := submission.ReadFile("SUNET")
+					if err != nil {
+						log.Println(err)
+						return grader.CheckResult{}, err
+					}
+					url = yoctogram.URL(sunet)
+				}
+
+				rendering, err = config.Render(ctx, url)
+				var loadErr *LoadError
+				if errors.As(err, &loadErr) {
+					return grader.CheckResult{Tests: []grader.GradescopeTest{
+						{Name: "Validate front page loads", MaxScore: points, Output: loadErr.Error()},
+					}}, nil
+				} else if err != nil {
+					return grader.CheckResult{}, err
+				}
+				record(screenshotPath, rendering.PNG)
+			}
+
+			test, heatmap := config.Judge(rendering, points)
+			if heatmap != nil {
+				encoded, err := encodePNG(heatmap)
+				if err == nil {
+					record(options.RecordingPath(name, ".heatmap.png"), encoded)
+				}
+			}
+			return grader.CheckResult{Score: test.Score, Tests: []grader.GradescopeTest{test}, Recording: rendering.record()}, nil
+		},
+	}
+}
+
+// replayRendering loads the rendering the named check recorded: its
+// screenshot at screenshotPath if there is one, and otherwise its hash in
+// options.ReplayResults.
+func replayRendering(name string, screenshotPath string, options grader.Options) (Rendering, error) {
+	if screenshotPath != "" {
+		screenshot, err := os.ReadFile(screenshotPath)
+		if err == nil {
+			return newRendering(screenshot)
+		}
+		if options.ReplayResults == "" {
+			log.Println(err)
+			return Rendering{}, err
+		}
+	}
+	if options.ReplayResults == "" {
+		return Rendering{}, errors.New("replaying needs the results.json or directory the screenshot was recorded to")
+	}
+
+	var record RenderingRecord
+	err := options.LoadRecording(name, &record)
+	if err != nil {
+		return Rendering{}, err
+	}
+	hash, err := perceptual.ParseHash(record.Hash)
+	if err != nil {
+		return Rendering{}, fmt.Errorf("%s: the %s check's hash: %w", options.ReplayResults, name, err)
+	}
+	return Rendering{Hash: hash, Quality: record.Quality}, nil
+}
+
+// record saves a recording if there's somewhere to. A failure to save one only costs
+// the recording, not the student's points.
+func record(path string, contents []byte) {
+	if path == "" {
+		return
+	}
+	err := os.WriteFile(path, contents, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, img)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,138 @@
+package frontpage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"infracourse.cloud/a2-grader/grader"
+	"infracourse.cloud/a2-grader/grader/perceptual"
+)
+
+// page is a 320x180 stand-in for a screenshot, with bands of content laid
+// out across it every period pixels by layout: rows, columns or a grid.
+func page(layout string, period int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, 320, 180))
+	for y := 0; y < 180; y++ {
+		for x := 0; x < 320; x++ {
+			dark := false
+			switch layout {
+			case "rows":
+				dark = y/period%2 == 0
+			case "columns":
+				dark = x/period%2 == 0
+			case "grid":
+				dark = (x/period+y/period)%2 == 0
+			}
+			img.SetGray(x, y, color.Gray{Y: 230})
+			if dark {
+				img.SetGray(x, y, color.Gray{Y: 40})
+			}
+		}
+	}
+	return img
+}
+
+func rendering(t *testing.T, img image.Image) Rendering {
+	t.Helper()
+	encoded, err := encodePNG(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newRendering(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// config is a config with two references: the rows page, with its image,
+// and the columns page, by its hash alone.
+func config(t *testing.T) (Config, string) {
+	t.Helper()
+	dir := t.TempDir()
+	rows := rendering(t, page("rows", 30))
+	err := os.WriteFile(filepath.Join(dir, "rows.png"), rows.PNG, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := rendering(t, page("columns", 40))
+
+	path := filepath.Join(dir, "frontpage.yaml")
+	err = os.WriteFile(path, []byte(fmt.Sprintf("references:\n  - name: rows\n    hash: %s\n    image: rows.png\n  - name: columns\n    hash: %s\n", rows.Hash, columns.Hash)), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, path
+}
+
+func TestScreenshotMatchesAnyReference(t *testing.T) {
+	c, _ := config(t)
+	for _, img := range []image.Image{page("rows", 30), page("columns", 40)} {
+		test, heatmap := c.Judge(rendering(t, img), 4)
+		if test.Score != 4 || heatmap != nil {
+			t.Errorf("scored %v with %q", test.Score, test.Output)
+		}
+	}
+}
+
+func TestFailingScreenshotShowsHeatmap(t *testing.T) {
+	c, _ := config(t)
+	failing := rendering(t, page("rows", 18))
+	if match, _ := perceptual.Nearest(failing.Hash, c.references); match.Reference.Name != "rows" {
+		t.Fatalf("the failing page is nearest %q, want the reference with an image", match.Reference.Name)
+	}
+
+	test, heatmap := c.Judge(failing, 4)
+	if test.Score != 0 {
+		t.Errorf("scored %v", test.Score)
+	}
+	if heatmap == nil || test.OutputFormat != "html" || !strings.Contains(test.Output, `src="data:image/png;base64,`) {
+		t.Errorf("the test has no heatmap attached: %q", test.Output)
+	}
+}
+
+func TestReplaysHashFromResults(t *testing.T) {
+	_, path := config(t)
+	results := filepath.Join(t.TempDir(), "results.json")
+	record := func(r Rendering) {
+		contents, err := json.Marshal(map[string]interface{}{"extra_data": map[string]interface{}{"recordings": map[string]interface{}{"frontpage": r.record()}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(results, contents, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	options := grader.Options{FrontpageConfigPath: path, Replay: true, ReplayResults: results}
+	check := Check("frontpage", 4, options)
+
+	record(rendering(t, page("columns", 40)))
+	result, err := check.Run(context.Background(), grader.Submission{})
+	if err != nil || result.Score != 4 {
+		t.Errorf("replaying a passing screenshot scored %v: %v", result.Score, err)
+	}
+
+	// Without the screenshot, a failing one is judged by its hash alone.
+	record(rendering(t, page("rows", 18)))
+	result, err = check.Run(context.Background(), grader.Submission{})
+	if err != nil || result.Score != 0 || len(result.Tests) != 1 || result.Tests[0].OutputFormat == "html" {
+		t.Errorf("replaying a failing screenshot scored %v with %+v: %v", result.Score, result.Tests, err)
+	}
+
+	_, err = Check("slo", 4, options).Run(context.Background(), grader.Submission{})
+	if err == nil {
+		t.Error("replayed a check results.json has no recording for")
+	}
+}
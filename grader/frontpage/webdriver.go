@@ -0,0 +1,217 @@
+package frontpage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"infracourse.cloud/a2-grader/grader"
+)
+
+// A Browser is a headless Chrome session driven through chromedriver over
+// the W3C WebDriver protocol.
+type Browser struct {
+	driver  *exec.Cmd
+	baseURL string
+	session string
+}
+
+// StartBrowser starts chromedriver and opens a session with a window of the
+// given size. Close the browser to stop both.
+func StartBrowser(ctx context.Context, chromedriver string, width int, height int) (*Browser, error) {
+	port, err := freePort()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	b := &Browser{baseURL: fmt.Sprintf("http://127.0.0.1:%d", port)}
+	b.driver = exec.Command(chromedriver, "--port="+strconv.Itoa(port))
+	err = b.driver.Start()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	err = b.waitReady(ctx)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	capabilities := map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"alwaysMatch": map[string]interface{}{
+				"browserName": "chrome",
+				"goog:chromeOptions": map[string]interface{}{
+					"args": []string{
+						"--headless",
+						"--no-sandbox",
+						"--disable-extensions",
+						"--disable-infobars",
+						"--hide-scrollbars",
+						fmt.Sprintf("--window-size=%d,%d", width, height),
+					},
+				},
+			},
+		},
+	}
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	err = b.command(ctx, "POST", "/session", capabilities, &session)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	b.session = "/session/" + session.SessionID
+	return b, nil
+}
+
+// waitReady polls chromedriver until it accepts sessions.
+func (b *Browser) waitReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	for {
+		var status struct {
+			Ready bool `json:"ready"`
+		}
+		err := b.command(ctx, "GET", "/status", nil, &status)
+		if err == nil && status.Ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("chromedriver didn't start: %w", ctx.Err())
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// Navigate loads url, returning once the browser considers it loaded.
+func (b *Browser) Navigate(ctx context.Context, url string) error {
+	return b.command(ctx, "POST", b.session+"/url", map[string]string{"url": url}, nil)
+}
+
+// ReadyState is the page's document.readyState.
+func (b *Browser) ReadyState(ctx context.Context) (string, error) {
+	var state string
+	err := b.command(ctx, "POST", b.session+"/execute/sync", map[string]interface{}{
+		"script": "return document.readyState",
+		"args":   []interface{}{},
+	}, &state)
+	return state, err
+}
+
+// Screenshot is a PNG of the browser's window.
+func (b *Browser) Screenshot(ctx context.Context) ([]byte, error) {
+	var encoded string
+	err := b.command(ctx, "GET", b.session+"/screenshot", nil, &encoded)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// Close ends the session and stops chromedriver, along with the browser.
+func (b *Browser) Close() {
+	if b.session != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := b.command(ctx, "DELETE", b.session, nil, nil)
+		cancel()
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	if b.driver.Process != nil {
+		_ = b.driver.Process.Kill()
+		_ = b.driver.Wait()
+	}
+}
+
+// command makes a WebDriver request, decoding the response's value into
+// value if it's not nil.
+func (b *Browser) command(ctx context.Context, method string, path string, body interface{}, value interface{}) error {
+	var encoded []byte
+	if body != nil {
+		var err error
+		encoded, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := grader.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		Value json.RawMessage `json:"value"`
+	}
+	err = json.Unmarshal(contents, &response)
+	if err != nil {
+		return fmt.Errorf("webdriver %s %s: malformed response: %w", method, path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(response.Value, &failure)
+		return &WebDriverError{Command: method + " " + path, Code: failure.Error, Message: failure.Message}
+	}
+
+	if value == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Value, value)
+}
+
+// A WebDriverError is an error reported by the browser, such as failing to
+// load a page.
+type WebDriverError struct {
+	Command string
+	Code    string
+	Message string
+}
+
+func (e *WebDriverError) Error() string {
+	return fmt.Sprintf("webdriver %s: %s: %s", e.Command, e.Code, e.Message)
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	addr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok {
+		return 0, errors.New("no TCP port")
+	}
+	return addr.Port, nil
+}
@@ -0,0 +1,203 @@
+// Package grader implements the grading pipeline shared by the assignment
+// orchestrators: the student's submission is synthesized to CloudFormation,
+// checked against an assignment's Rego rules, combined with any auxiliary
+// checks, and written out as Gradescope results.
+package grader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An Assignment describes how a single assignment is graded.
+type Assignment struct {
+	// Manifest holds the assignment's rule bundle and point values.
+	Manifest Manifest
+
+	// Rules holds the rule bundles built into the orchestrator. The
+	// manifest's rules_path names the bundle directory within it.
+	Rules fs.FS
+
+	// Checks are run after rule evaluation, in order.
+	Checks []Check
+}
+
+// Run grades the submission and writes its results file. A results file is
+// written even if grading fails, so the student always sees what went wrong.
+func Run(assignment Assignment, options Options) error {
+	log.Printf("grading with options %+v", options)
+
+	results, err := grade(assignment, options)
+	if err != nil {
+		log.Println(err)
+		results = failedResults(err)
+	}
+
+	writeErr := writeResults(options.ResultsPath, results)
+	if writeErr != nil {
+		log.Println(writeErr)
+		return writeErr
+	}
+
+	return err
+}
+
+func grade(assignment Assignment, options Options) (_ GradescopeOutput, err error) {
+	stage := "loading the assignment"
+	defer recoverStage(&stage, &err)
+
+	manifest := assignment.Manifest
+	for name := range manifest.Checks {
+		if !slices.ContainsFunc(assignment.Checks, func(check Check) bool { return check.Name == name }) {
+			return GradescopeOutput{}, &StageError{Stage: stage, Err: fmt.Errorf("manifest weights unknown check %q", name)}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(options.Timeout))
+
+	submission := Submission{Dir: options.SubmissionDir}
+
+	// The checks don't depend on the synthesized template, so they run
+	// alongside the rest of grading. If grading fails, they're cancelled.
+	checkResults := make([]CheckResult, len(assignment.Checks))
+	var checks sync.WaitGroup
+	defer func() {
+		cancel()
+		checks.Wait()
+	}()
+	for i, check := range assignment.Checks {
+		if options.SkipChecks {
+			checkResults[i] = checkSkipped(check)
+			continue
+		}
+
+		checks.Add(1)
+		go func(i int, check Check) {
+			defer checks.Done()
+			checkResults[i] = runCheck(ctx, check, submission, time.Duration(options.CheckTimeout))
+		}(i, check)
+	}
+
+	var resources map[string]interface{}
+	if options.TemplatePath != "" {
+		stage = "loading the synthesized template"
+		resources, err = loadTemplate(options.TemplatePath)
+		if err != nil {
+			log.Println(err)
+			return GradescopeOutput{}, &StageError{Stage: stage, Err: err}
+		}
+	} else {
+		stage = "packaging your submission"
+		submissionZip, err := makeSubmissionZip(ctx, submission, options.AppDir)
+		if err != nil {
+			log.Println(err)
+			return GradescopeOutput{}, &StageError{Stage: stage, Err: err}
+		}
+
+		stage = "synthesizing your CDK code"
+		synthContext, err := LoadSynthContext(options.SynthContextPath)
+		if err != nil {
+			log.Println(err)
+			return GradescopeOutput{}, &StageError{Stage: stage, Err: err}
+		}
+		synthCtx, synthCancel := context.WithTimeout(ctx, time.Duration(options.SynthTimeout))
+		resources, err = getCfnResources(synthCtx, options.SynthesizerURL, submissionZip, synthContext, manifest.RequiredStacks)
+		synthCancel()
+		if err != nil {
+			log.Println(err)
+			var synthErr *SynthError
+			return GradescopeOutput{}, &StageError{Stage: stage, Err: err, StudentFault: errors.As(err, &synthErr)}
+		}
+	}
+
+	if missing := missingStacks(resources, manifest.RequiredStacks); len(missing) > 0 {
+		return GradescopeOutput{}, &StageError{Stage: stage, Err: fmt.Errorf("your CDK app must define the stacks %s, but doesn't define %s", strings.Join(manifest.RequiredStacks, ", "), strings.Join(missing, ", ")), StudentFault: true}
+	}
+
+	stage = "loading the rules"
+	rules, err := getRuleBundle(options, assignment.Rules, manifest.RulesPath)
+	if err != nil {
+		log.Println(err)
+		return GradescopeOutput{}, &StageError{Stage: stage, Err: err}
+	}
+	log.Println("grading with rules revision", rules.Revision())
+	if !rules.Verified {
+		log.Println("warning: the rule bundle's signature was not verified")
+	}
+
+	catalog, err := ruleCatalog(rules)
+	if err != nil {
+		log.Println(err)
+		return GradescopeOutput{}, &StageError{Stage: stage, Err: err}
+	}
+
+	stage = "evaluating the rules"
+	failures, err := evalRules(ctx, rules, resources)
+	if err != nil {
+		log.Println(err)
+		return GradescopeOutput{}, &StageError{Stage: stage, Err: err}
+	}
+
+	stage = "running checks"
+	checks.Wait()
+
+	stage = "scoring"
+	failures = attributeViolations(catalog, failures)
+
+	gradescopeFormattedOutput := GradescopeOutput{
+		Score: manifest.TotalPoints - manifest.violationDeduction(failures),
+		Tests: make([]GradescopeTest, 0, len(catalog)+len(failures)),
+		ExtraData: map[string]interface{}{
+			"rules_revision": rules.Revision(),
+			"rules_digest":   rules.Digest,
+			"rules_verified": rules.Verified,
+		},
+	}
+
+	for _, failure := range failures {
+		gradescopeFormattedOutput.Tests = append(
+			gradescopeFormattedOutput.Tests,
+			failure.test(manifest.violationPoints(failure)),
+		)
+	}
+
+	for _, rule := range passingRules(catalog, failures, resources) {
+		gradescopeFormattedOutput.Tests = append(
+			gradescopeFormattedOutput.Tests,
+			rule.test(manifest.rulePoints(rule)),
+		)
+	}
+
+	// Gradescope keeps only results.json, so the checks' cassettes and
+	// recordings go in it for a regrade to replay, less the cassettes'
+	// bodies too large to embed.
+	cassettes := map[string]*Cassette{}
+	recordings := map[string]interface{}{}
+	for i, check := range assignment.Checks {
+		result := checkResults[i].scale(check.MaxScore, manifest.checkPoints(check))
+		gradescopeFormattedOutput.Score -= manifest.checkPoints(check) - result.Score
+		gradescopeFormattedOutput.Tests = append(gradescopeFormattedOutput.Tests, result.Tests...)
+		if result.Cassette != nil {
+			cassettes[check.Name] = result.Cassette.Embedded()
+		}
+		if result.Recording != nil {
+			recordings[check.Name] = result.Recording
+		}
+	}
+	if len(cassettes) > 0 {
+		gradescopeFormattedOutput.ExtraData["cassettes"] = cassettes
+	}
+	if len(recordings) > 0 {
+		gradescopeFormattedOutput.ExtraData["recordings"] = recordings
+	}
+	gradescopeFormattedOutput.Score = manifest.clamp(gradescopeFormattedOutput.Score)
+
+	return gradescopeFormattedOutput, nil
+}
@@ -0,0 +1,46 @@
+package grader
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+)
+
+const RESULTS_PATH = "/autograder/results/results.json"
+
+type GradescopeTest struct {
+	Score    float64  `json:"score"`
+	MaxScore float64  `json:"max_score"`
+	Name     string   `json:"name"`
+	Output   string   `json:"output"`
+	Tags     []string `json:"tags,omitempty"`
+
+	// OutputFormat is how Gradescope renders Output, such as "html";
+	// plain text by default.
+	OutputFormat string `json:"output_format,omitempty"`
+}
+
+type GradescopeOutput struct {
+	Score  float64          `json:"score"`
+	Output string           `json:"output,omitempty"`
+	Tests  []GradescopeTest `json:"tests"`
+
+	// ExtraData is stored by Gradescope alongside the results but not shown
+	// to students.
+	ExtraData map[string]interface{} `json:"extra_data,omitempty"`
+}
+
+func writeResults(path string, results GradescopeOutput) error {
+	output, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if path == "-" {
+		_, err = os.Stdout.Write(append(output, '\n'))
+		return err
+	}
+
+	return os.WriteFile(path, output, 0777)
+}
@@ -0,0 +1,26 @@
+package grader
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPClient is used for every request the grader makes, in place of
+// http.DefaultClient, which never times out. Its timeout is only a backstop:
+// requests should also carry their stage's context, whose deadline is
+// usually sooner.
+var HTTPClient = &http.Client{
+	Timeout: 10 * time.Minute,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   15 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   15 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+	},
+}
@@ -0,0 +1,138 @@
+package grader
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// A Manifest declares how an assignment is scored. It is loaded from a YAML
+// or JSON file so point values can be retuned without rebuilding the
+// orchestrator.
+type Manifest struct {
+	// RulesPath is the rule bundle directory within the orchestrator's
+	// built-in rules, e.g. a2-rules.
+	RulesPath string `json:"rules_path"`
+
+	// TotalPoints is the score of a submission with no deductions.
+	TotalPoints float64 `json:"total_points"`
+
+	// ViolationCost is deducted for every rule violation.
+	ViolationCost float64 `json:"violation_cost"`
+
+	// MaxViolationDeduction caps the total deducted for rule violations.
+	// Zero leaves the deduction uncapped.
+	MaxViolationDeduction float64 `json:"max_violation_deduction,omitempty"`
+
+	// MinScore and MaxScore clamp the final score when set.
+	MinScore *float64 `json:"min_score,omitempty"`
+	MaxScore *float64 `json:"max_score,omitempty"`
+
+	// Checks weights the assignment's auxiliary checks by name.
+	Checks map[string]CheckWeight `json:"checks,omitempty"`
+
+	// RequiredStacks are the stacks, by name, the submission must
+	// synthesize for it to be graded, and the ones whose resources the
+	// rules check.
+	RequiredStacks []string `json:"required_stacks,omitempty"`
+}
+
+type CheckWeight struct {
+	// Points is what a fully passing check is worth. The check's own
+	// results are scaled to fit.
+	Points float64 `json:"points"`
+}
+
+func LoadManifest(path string) (Manifest, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		log.Println(err)
+		return Manifest{}, err
+	}
+
+	var manifest Manifest
+	err = yaml.UnmarshalStrict(contents, &manifest)
+	if err != nil {
+		log.Println(err)
+		return Manifest{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if manifest.RulesPath == "" {
+		return Manifest{}, fmt.Errorf("%s: rules_path is required", path)
+	}
+	if manifest.TotalPoints <= 0 {
+		return Manifest{}, fmt.Errorf("%s: total_points must be positive", path)
+	}
+	if manifest.ViolationCost < 0 || manifest.MaxViolationDeduction < 0 {
+		return Manifest{}, fmt.Errorf("%s: violation deductions must not be negative", path)
+	}
+	if manifest.MinScore != nil && manifest.MaxScore != nil && *manifest.MinScore > *manifest.MaxScore {
+		return Manifest{}, fmt.Errorf("%s: min_score is greater than max_score", path)
+	}
+	for name, weight := range manifest.Checks {
+		if weight.Points < 0 {
+			return Manifest{}, fmt.Errorf("%s: check %q has negative points", path, name)
+		}
+	}
+
+	return manifest, nil
+}
+
+// violationPoints returns what violation costs, defaulting to the manifest's
+// violation_cost when the rule doesn't set its own points.
+func (m Manifest) violationPoints(violation Violation) float64 {
+	if violation.Points != nil {
+		return *violation.Points
+	}
+	return m.ViolationCost
+}
+
+// rulePoints returns what a passing rule is worth.
+func (m Manifest) rulePoints(rule Rule) float64 {
+	if rule.Points != nil {
+		return *rule.Points
+	}
+	return m.ViolationCost
+}
+
+func (m Manifest) violationDeduction(violations []Violation) float64 {
+	deduction := 0.0
+	for _, violation := range violations {
+		deduction += m.violationPoints(violation)
+	}
+	if m.MaxViolationDeduction > 0 && deduction > m.MaxViolationDeduction {
+		deduction = m.MaxViolationDeduction
+	}
+	return deduction
+}
+
+func (m Manifest) clamp(score float64) float64 {
+	if m.MinScore != nil && score < *m.MinScore {
+		score = *m.MinScore
+	}
+	if m.MaxScore != nil && score > *m.MaxScore {
+		score = *m.MaxScore
+	}
+	return score
+}
+
+// CheckPoints is what the manifest weights the named check at, for an
+// assignment to build a check whose points come from the manifest alone.
+func (m Manifest) CheckPoints(name string) (float64, error) {
+	weight, ok := m.Checks[name]
+	if !ok {
+		return 0, fmt.Errorf("the manifest doesn't weight the %s check", name)
+	}
+	return weight.Points, nil
+}
+
+// checkPoints returns what check is worth under the manifest, defaulting to
+// the check's own maximum score when the manifest doesn't weight it.
+func (m Manifest) checkPoints(check Check) float64 {
+	if weight, ok := m.Checks[check.Name]; ok {
+		return weight.Points
+	}
+	return check.MaxScore
+}
@@ -0,0 +1,354 @@
+package grader
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const MANIFEST_PATH = "/autograder/assignment.yaml"
+
+// CONFIG_PATH is the config file read when none is named. Unlike a named
+// one, it's fine for it not to exist.
+const CONFIG_PATH = "/autograder/config.yaml"
+
+const FLAG_VALIDATION_URI = "https://provisiondns.infracourse.cloud/a3/grader/"
+
+// FRONTPAGE_CONFIG_PATH lists the reference renderings of the front page.
+const FRONTPAGE_CONFIG_PATH = "/autograder/frontpage/frontpage.yaml"
+
+// COMPRESSION_RUBRIC_PATH says what counts as compressing an image well.
+const COMPRESSION_RUBRIC_PATH = "/autograder/compression.yaml"
+
+// SLO_CONFIG_PATH lists the endpoints the SLO check bursts and their
+// objectives.
+const SLO_CONFIG_PATH = "/autograder/slo.yaml"
+
+// SYNTH_CONTEXT_PATH is what the submission's CDK app is synthesized with in
+// place of lookups.
+const SYNTH_CONTEXT_PATH = "/autograder/synth-context.yaml"
+
+// GRADING_TIMEOUT leaves time to write results within Gradescope's default
+// ten minute limit. SYNTH_TIMEOUT is a little over the synthesizer lambda's
+// own timeout.
+const (
+	GRADING_TIMEOUT = 9 * time.Minute
+	SYNTH_TIMEOUT   = 6 * time.Minute
+	CHECK_TIMEOUT   = 5 * time.Minute
+)
+
+// Options locate everything the pipeline reads and writes. The defaults are
+// the Gradescope container's layout; overriding them lets staff grade a
+// submission on their own machine, without network access when given a
+// local rules directory and either a local synthesizer or a pre-synthesized
+// template.
+//
+// Options are read from, in increasing order of precedence, a config file,
+// GRADER_* environment variables, and command line flags. The JSON names
+// below are the config file's keys.
+type Options struct {
+	// ConfigPath is the config file the options were read from, if any.
+	ConfigPath string `json:"-"`
+
+	ManifestPath string `json:"manifest"`
+
+	// SubmissionDir is the student's checkout of the CDK code.
+	SubmissionDir string `json:"submission"`
+
+	// AppDir is a local checkout of yoctogram-app to package with the
+	// submission. If empty, the submission's own app directory is used,
+	// cloning it from GitHub when missing.
+	AppDir string `json:"app,omitempty"`
+
+	// RulesDir is a local rule bundle directory. If empty, the manifest's
+	// rules_path is loaded from the rules built into the orchestrator.
+	RulesDir string `json:"rules,omitempty"`
+
+	// RulesKeyPath is the public key the rule bundle's signature is checked
+	// against.
+	RulesKeyPath string `json:"rules_key"`
+
+	// UnsignedRules skips checking the rule bundle's signature. It's only
+	// meant for developing rules locally, before they've been signed, so it
+	// can only be set by flag.
+	UnsignedRules bool `json:"-"`
+
+	SynthesizerURL string `json:"synthesizer"`
+
+	// SynthContextPath is the account, region and lookup results the
+	// synthesizer synthesizes the submission with.
+	SynthContextPath string `json:"synth_context"`
+
+	// TemplatePath is a synthesized CloudFormation template to grade in
+	// place of calling the synthesizer.
+	TemplatePath string `json:"template,omitempty"`
+
+	// YoctogramURL is the deployment the runtime checks probe. If empty,
+	// it's found from the SUNet ID in the submission.
+	YoctogramURL string `json:"yoctogram,omitempty"`
+
+	// FrontpageConfigPath is where A2's front page check finds its
+	// reference renderings and thresholds.
+	FrontpageConfigPath string `json:"frontpage"`
+
+	// CompressionRubricPath is the rubric A3's runtime check judges the
+	// compression of posted images by.
+	CompressionRubricPath string `json:"compression"`
+
+	// SLOConfigPath is where the SLO check finds the endpoints to burst and
+	// the latency and error rate objectives they must meet.
+	SLOConfigPath string `json:"slo"`
+
+	// FlagValidationURL is where A3's flag check validates a student's flag.
+	FlagValidationURL string `json:"flag_validation"`
+
+	// GraderToken authenticates the grader to course infrastructure such as
+	// the flag validation endpoint.
+	GraderToken Secret `json:"grader_token,omitempty"`
+
+	// ResultsPath is where results are written, or "-" for stdout.
+	ResultsPath string `json:"results"`
+
+	// CassetteDir is where checks that support it record what they saw of
+	// the student's deployment, such as their HTTP exchanges with it. If
+	// empty, nothing is recorded.
+	CassetteDir string `json:"cassettes"`
+
+	// Replay re-scores those checks from their recordings in CassetteDir
+	// rather than from the deployment, such as for a regrade.
+	Replay bool `json:"replay,omitempty"`
+
+	// ReplayResults is a results.json whose cassettes and recordings Replay
+	// replays in place of those in CassetteDir, since Gradescope keeps only
+	// results.json.
+	ReplayResults string `json:"replay_results,omitempty"`
+
+	// Timeout bounds the whole of grading. Within it, SynthTimeout bounds
+	// the synthesizer call and CheckTimeout each check without a timeout of
+	// its own.
+	Timeout      Duration `json:"timeout"`
+	SynthTimeout Duration `json:"synth_timeout"`
+	CheckTimeout Duration `json:"check_timeout"`
+
+	// SkipChecks skips the auxiliary checks, which generally need network
+	// access or the Gradescope container. It can only be set by flag.
+	SkipChecks bool `json:"-"`
+}
+
+// A Secret is a string option that must never be logged. It prints as
+// "[redacted]" however it's formatted; convert it to a string to use it.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+func (s Secret) GoString() string {
+	return fmt.Sprintf("%q", s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", s.String())), nil
+}
+
+func (s *Secret) Set(value string) error {
+	*s = Secret(value)
+	return nil
+}
+
+// A Duration is a time.Duration option, written like "5m" or "90s".
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if parsed <= 0 {
+		return fmt.Errorf("duration %s must be positive", value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	return d.Set(value)
+}
+
+func DefaultOptions() Options {
+	return Options{
+		ManifestPath:          MANIFEST_PATH,
+		SubmissionDir:         SUBMISSION_DIR,
+		RulesKeyPath:          RULES_KEY_PATH,
+		SynthesizerURL:        LAMBDA_GATEWAY_URI,
+		SynthContextPath:      SYNTH_CONTEXT_PATH,
+		FlagValidationURL:     FLAG_VALIDATION_URI,
+		FrontpageConfigPath:   FRONTPAGE_CONFIG_PATH,
+		CompressionRubricPath: COMPRESSION_RUBRIC_PATH,
+		SLOConfigPath:         SLO_CONFIG_PATH,
+		ResultsPath:           RESULTS_PATH,
+		CassetteDir:           filepath.Dir(RESULTS_PATH),
+		Timeout:               Duration(GRADING_TIMEOUT),
+		SynthTimeout:          Duration(SYNTH_TIMEOUT),
+		CheckTimeout:          Duration(CHECK_TIMEOUT),
+	}
+}
+
+// ENV_VARS maps each environment variable to the flag it stands in for.
+var ENV_VARS = map[string]string{
+	"GRADER_MANIFEST":        "manifest",
+	"GRADER_SUBMISSION":      "submission",
+	"GRADER_APP":             "app",
+	"GRADER_RULES":           "rules",
+	"GRADER_RULES_KEY":       "rules-key",
+	"GRADER_SYNTHESIZER":     "synthesizer",
+	"GRADER_SYNTH_CONTEXT":   "synth-context",
+	"GRADER_TEMPLATE":        "template",
+	"GRADER_YOCTOGRAM":       "yoctogram",
+	"GRADER_FRONTPAGE":       "frontpage",
+	"GRADER_COMPRESSION":     "compression",
+	"GRADER_SLO":             "slo",
+	"GRADER_FLAG_VALIDATION": "flag-validation",
+	"GRADER_TOKEN":           "grader-token",
+	"GRADER_RESULTS":         "results",
+	"GRADER_CASSETTES":       "cassettes",
+	"GRADER_REPLAY":          "replay",
+	"GRADER_REPLAY_RESULTS":  "replay-results",
+	"GRADER_TIMEOUT":         "timeout",
+	"GRADER_SYNTH_TIMEOUT":   "synth-timeout",
+	"GRADER_CHECK_TIMEOUT":   "check-timeout",
+}
+
+func (o *Options) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet("run_autograder", flag.ContinueOnError)
+	flags.StringVar(&o.ConfigPath, "config", o.ConfigPath, "config file (default "+CONFIG_PATH+" if it exists)")
+	flags.StringVar(&o.ManifestPath, "manifest", o.ManifestPath, "assignment manifest")
+	flags.StringVar(&o.SubmissionDir, "submission", o.SubmissionDir, "submission directory")
+	flags.StringVar(&o.AppDir, "app", o.AppDir, "local yoctogram-app checkout to package instead of cloning it")
+	flags.StringVar(&o.RulesDir, "rules", o.RulesDir, "local rule bundle directory to use instead of the built-in rules")
+	flags.StringVar(&o.RulesKeyPath, "rules-key", o.RulesKeyPath, "public key the rule bundle must be signed with")
+	flags.BoolVar(&o.UnsignedRules, "unsigned-rules", o.UnsignedRules, "grade with a rule bundle without checking its signature, for developing rules")
+	flags.StringVar(&o.SynthesizerURL, "synthesizer", o.SynthesizerURL, "synthesizer URL")
+	flags.StringVar(&o.SynthContextPath, "synth-context", o.SynthContextPath, "account, region and lookup results to synthesize the submission with")
+	flags.StringVar(&o.TemplatePath, "template", o.TemplatePath, "pre-synthesized CloudFormation template to grade instead of calling the synthesizer")
+	flags.StringVar(&o.YoctogramURL, "yoctogram", o.YoctogramURL, "Yoctogram deployment to probe instead of the submitter's")
+	flags.StringVar(&o.FrontpageConfigPath, "frontpage", o.FrontpageConfigPath, "reference renderings of the front page")
+	flags.StringVar(&o.CompressionRubricPath, "compression", o.CompressionRubricPath, "rubric for how posted images should be compressed")
+	flags.StringVar(&o.SLOConfigPath, "slo", o.SLOConfigPath, "endpoints the SLO check bursts and their objectives")
+	flags.StringVar(&o.FlagValidationURL, "flag-validation", o.FlagValidationURL, "flag validation URL")
+	flags.Var(&o.GraderToken, "grader-token", "token authenticating the grader to course infrastructure; prefer GRADER_TOKEN or the config file")
+	flags.StringVar(&o.ResultsPath, "results", o.ResultsPath, `results file, or "-" for stdout`)
+	flags.StringVar(&o.CassetteDir, "cassettes", o.CassetteDir, "directory to record checks' HTTP exchanges to, or empty not to record them")
+	flags.BoolVar(&o.Replay, "replay", o.Replay, "re-score checks from their recorded cassettes instead of the deployment")
+	flags.StringVar(&o.ReplayResults, "replay-results", o.ReplayResults, "results.json to replay cassettes and recordings from instead of the cassettes directory")
+	flags.Var(&o.Timeout, "timeout", "time limit for grading as a whole")
+	flags.Var(&o.SynthTimeout, "synth-timeout", "time limit for synthesizing the submission")
+	flags.Var(&o.CheckTimeout, "check-timeout", "time limit for each auxiliary check")
+	flags.BoolVar(&o.SkipChecks, "skip-checks", o.SkipChecks, "skip the auxiliary checks")
+	return flags
+}
+
+// ParseOptions reads the orchestrator's options from its config file,
+// environment and command line. With none of them it returns DefaultOptions,
+// which is how Gradescope runs the orchestrator.
+func ParseOptions(args []string) (Options, error) {
+	options := DefaultOptions()
+
+	// The flags are parsed once just to find the config file, and again once
+	// the config file and environment have been applied, so that flags take
+	// precedence over both.
+	flagged := options
+	flags := flagged.flagSet()
+	err := flags.Parse(args)
+	if err != nil {
+		return options, err
+	}
+	if flags.NArg() != 0 {
+		return options, errors.New("unexpected arguments")
+	}
+
+	configPath := flagged.ConfigPath
+	if configPath == "" {
+		configPath = os.Getenv("GRADER_CONFIG")
+	}
+	if configPath == "" {
+		if _, err := os.Stat(CONFIG_PATH); err == nil {
+			configPath = CONFIG_PATH
+		}
+	}
+	if configPath != "" {
+		err = options.loadConfig(configPath)
+		if err != nil {
+			return options, err
+		}
+		options.ConfigPath = configPath
+	}
+
+	flags = options.flagSet()
+	for env, name := range ENV_VARS {
+		if value, ok := os.LookupEnv(env); ok {
+			err = flags.Set(name, value)
+			if err != nil {
+				return options, fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+
+	err = flags.Parse(args)
+	if err != nil {
+		return options, err
+	}
+
+	return options, nil
+}
+
+func (o *Options) loadConfig(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	err = yaml.UnmarshalStrict(contents, o)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// RecordingPath is where the named check records something with the given
+// suffix, or empty if recordings aren't kept.
+func (o Options) RecordingPath(check string, suffix string) string {
+	if o.CassetteDir == "" {
+		return ""
+	}
+	return filepath.Join(o.CassetteDir, check+suffix)
+}
+
+// CassettePath is where the named check's cassette is recorded, or empty if
+// cassettes aren't recorded.
+func (o Options) CassettePath(check string) string {
+	return o.RecordingPath(check, ".cassette.json")
+}
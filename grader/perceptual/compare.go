@@ -0,0 +1,138 @@
+package perceptual
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// A Reference is one acceptable rendering, such as of a page in a
+// particular browser.
+type Reference struct {
+	Name string
+	Hash Hash
+
+	// Threshold is how many bits a hash may differ from this reference's in
+	// and still match it.
+	Threshold int
+
+	// Image is the rendering itself, if available, for heatmaps.
+	Image image.Image
+}
+
+// A Match is how close a hash came to the nearest reference.
+type Match struct {
+	Reference Reference
+	Distance  int
+}
+
+func (m Match) Matched() bool {
+	return m.Distance <= m.Reference.Threshold
+}
+
+// Nearest is the reference closest to hash relative to its threshold, so
+// that a match is found if there is one. It's false if there are no
+// references.
+func Nearest(hash Hash, references []Reference) (Match, bool) {
+	var best Match
+	found := false
+	for _, reference := range references {
+		match := Match{Reference: reference, Distance: hash.Distance(reference.Hash)}
+		if !found || match.Distance-match.Reference.Threshold < best.Distance-best.Reference.Threshold {
+			best = match
+			found = true
+		}
+	}
+	return best, found
+}
+
+// HEATMAP_CELL is the size in pixels of the squares a heatmap compares.
+const HEATMAP_CELL = 16
+
+// Heatmap shows where img differs from reference: img in grayscale, with
+// each HEATMAP_CELL square tinted red by how far its average luminance is
+// from the same square of reference, scaled to img's size.
+func Heatmap(img image.Image, reference image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	cols := (bounds.Dx() + HEATMAP_CELL - 1) / HEATMAP_CELL
+	rows := (bounds.Dy() + HEATMAP_CELL - 1) / HEATMAP_CELL
+	got := cellLuminance(img, rows, cols)
+	want := cellLuminance(reference, rows, cols)
+
+	heatmap := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(heatmap, heatmap.Bounds(), img, bounds.Min, draw.Src)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			cell := (y/HEATMAP_CELL)*cols + x/HEATMAP_CELL
+			heat := got[cell] - want[cell]
+			if heat < 0 {
+				heat = -heat
+			}
+			heat /= 255
+
+			gray := float64(color.GrayModel.Convert(heatmap.At(x, y)).(color.Gray).Y) / 2
+			heatmap.SetRGBA(x, y, color.RGBA{
+				R: uint8(gray + (255-gray)*heat),
+				G: uint8(gray * (1 - heat)),
+				B: uint8(gray * (1 - heat)),
+				A: 255,
+			})
+		}
+	}
+	return heatmap
+}
+
+// cellLuminance averages img's luminance over a rows by cols grid, however
+// big img is.
+func cellLuminance(img image.Image, rows int, cols int) []float64 {
+	bounds := img.Bounds()
+	sums := make([]float64, rows*cols)
+	counts := make([]int, rows*cols)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := (y - bounds.Min.Y) * rows / bounds.Dy()
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			col := (x - bounds.Min.X) * cols / bounds.Dx()
+			r, g, b, _ := img.At(x, y).RGBA()
+			sums[row*cols+col] += 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+			counts[row*cols+col]++
+		}
+	}
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= float64(counts[i])
+		}
+	}
+	return sums
+}
+
+// Shrink scales img down to width pixels wide, averaging the pixels each
+// one covers. An image no wider than width is returned as is.
+func Shrink(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width || width <= 0 {
+		return img
+	}
+	height := max(bounds.Dy()*width/bounds.Dx(), 1)
+
+	sums := make([][4]float64, width*height)
+	counts := make([]int, width*height)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := (y - bounds.Min.Y) * height / bounds.Dy()
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := row*width + (x-bounds.Min.X)*width/bounds.Dx()
+			r, g, b, a := img.At(x, y).RGBA()
+			sums[i][0] += float64(r >> 8)
+			sums[i][1] += float64(g >> 8)
+			sums[i][2] += float64(b >> 8)
+			sums[i][3] += float64(a >> 8)
+			counts[i]++
+		}
+	}
+
+	small := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, sum := range sums {
+		n := float64(max(counts[i], 1))
+		small.SetRGBA(i%width, i/width, color.RGBA{R: uint8(sum[0] / n), G: uint8(sum[1] / n), B: uint8(sum[2] / n), A: uint8(sum[3] / n)})
+	}
+	return small
+}
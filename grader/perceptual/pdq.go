@@ -0,0 +1,225 @@
+// Package perceptual compares images by how they look rather than by their
+// bytes: by PDQ hashes, 256-bit hashes that differ in few bits between
+// images that look alike, such as two renderings of the same web page, and
+// by SSIM, which scores how much of an image's structure survives a lossy
+// copy of it.
+package perceptual
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// HASH_BITS is the length of a PDQ hash.
+const HASH_BITS = 256
+
+// The image is reduced to a HASH_DOWNSAMPLE square of luminance, whose
+// lowest HASH_DCT square of DCT coefficients make up the hash.
+const (
+	HASH_DOWNSAMPLE = 64
+	HASH_DCT        = 16
+)
+
+// A Hash is a PDQ hash. Bit k is bit k%16 of word k/16, as in the reference
+// implementation, so that hashes can be exchanged with other PDQ tools in
+// its hex format.
+type Hash [HASH_BITS / 16]uint16
+
+// ParseHash reads a hash in the reference implementation's hex format:
+// 64 digits, most significant word first.
+func ParseHash(s string) (Hash, error) {
+	decoded, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return Hash{}, fmt.Errorf("malformed PDQ hash %q: %w", s, err)
+	}
+	if len(decoded) != HASH_BITS/8 {
+		return Hash{}, fmt.Errorf("malformed PDQ hash %q: %d digits, not %d", s, len(decoded)*2, HASH_BITS/4)
+	}
+
+	var h Hash
+	for i := range h {
+		j := 2 * (len(h) - 1 - i)
+		h[i] = uint16(decoded[j])<<8 | uint16(decoded[j+1])
+	}
+	return h, nil
+}
+
+func (h Hash) String() string {
+	var b strings.Builder
+	for i := len(h) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%04x", h[i])
+	}
+	return b.String()
+}
+
+// Distance is the number of bits in which two hashes differ.
+func (h Hash) Distance(other Hash) int {
+	distance := 0
+	for i := range h {
+		distance += bits.OnesCount16(h[i] ^ other[i])
+	}
+	return distance
+}
+
+// PDQ hashes an image. Quality, from 0 to 100, is how much detail the image
+// has; hashes of featureless images, such as blank pages, are unreliable.
+func PDQ(img image.Image) (hash Hash, quality int) {
+	rows, cols := img.Bounds().Dy(), img.Bounds().Dx()
+	if rows == 0 || cols == 0 {
+		return Hash{}, 0
+	}
+
+	luma := luminance(img)
+	buffer := make([]float64, len(luma))
+	jarosz(luma, buffer, rows, cols, windowSize(cols, HASH_DOWNSAMPLE), windowSize(rows, HASH_DOWNSAMPLE))
+	small := decimate(luma, rows, cols)
+
+	quality = imageQuality(small)
+	coefficients := dct(small)
+
+	median := medianOf(coefficients[:])
+	for k, c := range coefficients {
+		if c > median {
+			hash[k/16] |= 1 << (k % 16)
+		}
+	}
+	return hash, quality
+}
+
+func luminance(img image.Image) []float64 {
+	bounds := img.Bounds()
+	luma := make([]float64, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			luma = append(luma, 0.299*float64(r>>8)+0.587*float64(g>>8)+0.114*float64(b>>8))
+		}
+	}
+	return luma
+}
+
+// windowSize is the width of the box filter that blurs oldSize pixels
+// enough to be sampled down to newSize.
+func windowSize(oldSize int, newSize int) int {
+	return (oldSize + 2*newSize - 1) / (2 * newSize)
+}
+
+// jarosz blurs luma in place with two passes of a box filter along its rows
+// and then its columns, which approximates a tent filter.
+func jarosz(luma []float64, buffer []float64, rows int, cols int, rowWindow int, colWindow int) {
+	for pass := 0; pass < 2; pass++ {
+		for i := 0; i < rows; i++ {
+			box(luma[i*cols:], buffer[i*cols:], cols, 1, rowWindow)
+		}
+		for j := 0; j < cols; j++ {
+			box(buffer[j:], luma[j:], rows, cols, colWindow)
+		}
+	}
+}
+
+// box is a box filter over length values of in, stride apart, with windows
+// that shrink at the ends rather than reading past them.
+func box(in []float64, out []float64, length int, stride int, window int) {
+	half := (window + 2) / 2
+	phases := [4]int{half - 1, window - half + 1, length - window, half - 1}
+
+	left, right, o := 0, 0, 0
+	sum, size := 0.0, 0
+	for i := 0; i < phases[0]; i++ {
+		sum += in[right]
+		size++
+		right += stride
+	}
+	for i := 0; i < phases[1]; i++ {
+		sum += in[right]
+		size++
+		out[o] = sum / float64(size)
+		right += stride
+		o += stride
+	}
+	for i := 0; i < phases[2]; i++ {
+		sum += in[right] - in[left]
+		out[o] = sum / float64(size)
+		left += stride
+		right += stride
+		o += stride
+	}
+	for i := 0; i < phases[3]; i++ {
+		sum -= in[left]
+		size--
+		out[o] = sum / float64(size)
+		left += stride
+		o += stride
+	}
+}
+
+func decimate(luma []float64, rows int, cols int) *[HASH_DOWNSAMPLE][HASH_DOWNSAMPLE]float64 {
+	var out [HASH_DOWNSAMPLE][HASH_DOWNSAMPLE]float64
+	for i := range out {
+		row := int((float64(i) + 0.5) * float64(rows) / HASH_DOWNSAMPLE)
+		for j := range out[i] {
+			col := int((float64(j) + 0.5) * float64(cols) / HASH_DOWNSAMPLE)
+			out[i][j] = luma[row*cols+col]
+		}
+	}
+	return &out
+}
+
+// imageQuality scores the image's detail by its gradients.
+func imageQuality(small *[HASH_DOWNSAMPLE][HASH_DOWNSAMPLE]float64) int {
+	gradients := 0
+	for i := 0; i < HASH_DOWNSAMPLE-1; i++ {
+		for j := 0; j < HASH_DOWNSAMPLE; j++ {
+			gradients += int(math.Abs(small[i][j]-small[i+1][j]) * 100 / 255)
+		}
+	}
+	for i := 0; i < HASH_DOWNSAMPLE; i++ {
+		for j := 0; j < HASH_DOWNSAMPLE-1; j++ {
+			gradients += int(math.Abs(small[i][j]-small[i][j+1]) * 100 / 255)
+		}
+	}
+	return min(gradients/90, 100)
+}
+
+// dct is the lowest HASH_DCT square of the downsampled image's 2D DCT, in
+// row-major order.
+func dct(small *[HASH_DOWNSAMPLE][HASH_DOWNSAMPLE]float64) [HASH_DCT * HASH_DCT]float64 {
+	var matrix [HASH_DCT][HASH_DOWNSAMPLE]float64
+	scale := math.Sqrt(2.0 / HASH_DOWNSAMPLE)
+	for i := range matrix {
+		for j := range matrix[i] {
+			matrix[i][j] = scale * math.Cos(math.Pi/2/HASH_DOWNSAMPLE*float64(i+1)*float64(2*j+1))
+		}
+	}
+
+	var partial [HASH_DCT][HASH_DOWNSAMPLE]float64
+	for i := range partial {
+		for j := range partial[i] {
+			for k := 0; k < HASH_DOWNSAMPLE; k++ {
+				partial[i][j] += matrix[i][k] * small[k][j]
+			}
+		}
+	}
+
+	var out [HASH_DCT * HASH_DCT]float64
+	for i := 0; i < HASH_DCT; i++ {
+		for j := 0; j < HASH_DCT; j++ {
+			for k := 0; k < HASH_DOWNSAMPLE; k++ {
+				out[i*HASH_DCT+j] += partial[i][k] * matrix[j][k]
+			}
+		}
+	}
+	return out
+}
+
+// medianOf is the lower median, as the reference implementation uses.
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[(len(sorted)-1)/2]
+}
@@ -0,0 +1,71 @@
+package perceptual
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// PDQHASH_VECTOR is the bit vector pdqhash.compute returned for the Yoctogram
+// front page, which the Python check compared screenshots against. Element
+// k is the bit of the DCT coefficient in row k/16 and column k%16.
+var PDQHASH_VECTOR = [HASH_BITS]int{0, 1, 1, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 0, 1, 1, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 0, 0, 1, 1, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 1, 0, 0, 1, 1, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 1, 1, 0, 0, 1, 0, 0, 1, 1, 0, 1, 1, 0, 0, 1, 0, 1, 1, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 0, 0, 1, 1, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 1, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 1, 0, 0, 1, 1, 0, 0, 1, 0, 1, 1, 1, 0, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 0, 1, 1, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 1, 1, 0, 0, 1, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 0, 1, 1, 0, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1}
+
+// PDQHASH_HEX is PDQHASH_VECTOR in the reference implementation's hex format,
+// as pdq-hasher prints it: word 15 first, each word's bit 15 first.
+const PDQHASH_HEX = "998c667366733336998e99ccccc96679333233269b26ccd9ccd9333333263326"
+
+// basisImage is a HASH_DOWNSAMPLE square gray image made of the DCT basis
+// functions PDQ hashes, each added in where vector has a 1 and subtracted
+// where it has a 0, so that the reference implementation hashes it to
+// vector. At that size PDQ neither blurs nor downsamples.
+func basisImage(vector [HASH_BITS]int) image.Image {
+	var basis [HASH_DCT][HASH_DOWNSAMPLE]float64
+	for i := range basis {
+		for j := range basis[i] {
+			basis[i][j] = math.Sqrt(2.0/HASH_DOWNSAMPLE) * math.Cos(math.Pi/2/HASH_DOWNSAMPLE*float64(i+1)*float64(2*j+1))
+		}
+	}
+
+	img := image.NewGray(image.Rect(0, 0, HASH_DOWNSAMPLE, HASH_DOWNSAMPLE))
+	for y := 0; y < HASH_DOWNSAMPLE; y++ {
+		for x := 0; x < HASH_DOWNSAMPLE; x++ {
+			luma := 128.0
+			for k, bit := range vector {
+				sign := -1.0
+				if bit == 1 {
+					sign = 1
+				}
+				luma += 40 * sign * basis[k/HASH_DCT][y] * basis[k%HASH_DCT][x]
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(math.Round(luma))})
+		}
+	}
+	return img
+}
+
+func TestPDQMatchesTheReferenceBitOrder(t *testing.T) {
+	hash, _ := PDQ(basisImage(PDQHASH_VECTOR))
+	if hash.String() != PDQHASH_HEX {
+		t.Errorf("hashed to %s, want %s", hash, PDQHASH_HEX)
+	}
+	for k, bit := range PDQHASH_VECTOR {
+		if got := int(hash[k/16]>>(k%16)) & 1; got != bit {
+			t.Errorf("bit %d is %d, want %d", k, got, bit)
+		}
+	}
+}
+
+func TestParseHashRoundTrips(t *testing.T) {
+	hash, err := ParseHash(PDQHASH_HEX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash.String() != PDQHASH_HEX {
+		t.Errorf("parsed %s as %s", PDQHASH_HEX, hash)
+	}
+	if _, err := ParseHash(PDQHASH_HEX[2:]); err == nil {
+		t.Error("parsed a hash two digits short")
+	}
+}
@@ -0,0 +1,57 @@
+package perceptual
+
+import "image"
+
+// SSIM_WINDOW is the side of the square windows SSIM compares, which
+// overlap by half.
+const SSIM_WINDOW = 8
+
+// The constants that keep SSIM stable where windows are nearly flat, for
+// 8-bit luminance.
+const (
+	SSIM_C1 = (0.01 * 255) * (0.01 * 255)
+	SSIM_C2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// SSIM is the mean structural similarity of two images' luminance, from -1
+// to 1 where 1 means they're identical. Images of different sizes are
+// compared at the size of the smaller, the larger averaged down to it.
+func SSIM(a image.Image, b image.Image) float64 {
+	width := min(a.Bounds().Dx(), b.Bounds().Dx())
+	height := min(a.Bounds().Dy(), b.Bounds().Dy())
+	if width == 0 || height == 0 {
+		return 0
+	}
+	x := cellLuminance(a, height, width)
+	y := cellLuminance(b, height, width)
+
+	window := min(SSIM_WINDOW, width, height)
+	step := max(window/2, 1)
+	n := float64(window * window)
+
+	total, windows := 0.0, 0
+	for top := 0; top+window <= height; top += step {
+		for left := 0; left+window <= width; left += step {
+			var sumX, sumY, sumXX, sumYY, sumXY float64
+			for i := top; i < top+window; i++ {
+				for j := left; j < left+window; j++ {
+					p, q := x[i*width+j], y[i*width+j]
+					sumX += p
+					sumY += q
+					sumXX += p * p
+					sumYY += q * q
+					sumXY += p * q
+				}
+			}
+			meanX, meanY := sumX/n, sumY/n
+			varX := sumXX/n - meanX*meanX
+			varY := sumYY/n - meanY*meanY
+			cov := sumXY/n - meanX*meanY
+
+			total += (2*meanX*meanY + SSIM_C1) * (2*cov + SSIM_C2) /
+				((meanX*meanX + meanY*meanY + SSIM_C1) * (varX + varY + SSIM_C2))
+			windows++
+		}
+	}
+	return total / float64(windows)
+}
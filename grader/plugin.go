@@ -0,0 +1,228 @@
+package grader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PLUGIN_PROTOCOL_VERSION is the version of the check plugin protocol the
+// grader speaks. Plugins must echo it back; it changes only when the input
+// or output changes incompatibly.
+const PLUGIN_PROTOCOL_VERSION = 1
+
+// PLUGIN_LOG_LIMIT bounds how much of a plugin's stdout and stderr is kept.
+const PLUGIN_LOG_LIMIT = 64 * 1024
+
+// PluginInput is written to a check plugin's stdin as JSON.
+type PluginInput struct {
+	ProtocolVersion int     `json:"protocol_version"`
+	Check           string  `json:"check"`
+	MaxScore        float64 `json:"max_score"`
+	SubmissionDir   string  `json:"submission_dir"`
+
+	// OutputPath is where the plugin writes its PluginOutput. Using a file
+	// rather than stdout leaves the plugin free to print whatever it likes.
+	OutputPath string `json:"output_path"`
+
+	// Deadline is when the plugin will be killed, in RFC 3339 format.
+	Deadline string `json:"deadline,omitempty"`
+}
+
+// PluginOutput is what a check plugin writes to its input's output_path.
+type PluginOutput struct {
+	ProtocolVersion int `json:"protocol_version"`
+
+	// Score defaults to the sum of the tests' scores. The tests' max_scores
+	// must add up to the check's.
+	Score *float64         `json:"score,omitempty"`
+	Tests []GradescopeTest `json:"tests"`
+
+	// Logs are copied to the autograder log, which only staff can see.
+	Logs []string `json:"logs,omitempty"`
+}
+
+// A PluginError means a plugin failed or broke the protocol. It's reported
+// like any other check failure; the plugin's output goes to the log.
+type PluginError struct {
+	Check string
+	Err   error
+}
+
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("%s check plugin: %v", e.Check, e.Err)
+}
+
+func (e *PluginError) Unwrap() error {
+	return e.Err
+}
+
+// PluginCheck runs a check plugin: the command is given a PluginInput on
+// stdin and must write a PluginOutput to the input's output_path before
+// exiting successfully. Its stdout and stderr are captured to the log.
+// A plugin that exits unsuccessfully, runs past its deadline, or writes
+// output that's missing, malformed or for another protocol version is a
+// PluginError, and its check scores zero like any other that fails.
+func PluginCheck(name string, maxScore float64, command ...string) Check {
+	check := Check{
+		Name:     name,
+		MaxScore: maxScore,
+	}
+	check.Run = func(ctx context.Context, submission Submission) (CheckResult, error) {
+		result, err := runPlugin(ctx, check, submission, command)
+		if err != nil {
+			return CheckResult{}, &PluginError{Check: name, Err: err}
+		}
+		return result, nil
+	}
+	return check
+}
+
+func runPlugin(ctx context.Context, check Check, submission Submission, command []string) (CheckResult, error) {
+	if len(command) == 0 {
+		return CheckResult{}, errors.New("no command given")
+	}
+
+	dir, err := os.MkdirTemp("", "check-"+check.Name)
+	if err != nil {
+		log.Println(err)
+		return CheckResult{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := PluginInput{
+		ProtocolVersion: PLUGIN_PROTOCOL_VERSION,
+		Check:           check.Name,
+		MaxScore:        check.MaxScore,
+		SubmissionDir:   submission.Dir,
+		OutputPath:      filepath.Join(dir, "output.json"),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		input.Deadline = deadline.Format(time.RFC3339)
+	}
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		log.Println(err)
+		return CheckResult{}, err
+	}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	if cmd.Err != nil {
+		log.Println(cmd.Err)
+		return CheckResult{}, cmd.Err
+	}
+	stdout := &limitedBuffer{limit: PLUGIN_LOG_LIMIT}
+	stderr := &limitedBuffer{limit: PLUGIN_LOG_LIMIT}
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Don't wait indefinitely on children the plugin leaves holding stdout,
+	// such as a browser, once the plugin itself has been killed.
+	cmd.WaitDelay = 5 * time.Second
+
+	err = cmd.Run()
+	logPluginOutput(check.Name, "stdout", stdout)
+	logPluginOutput(check.Name, "stderr", stderr)
+	if err != nil {
+		log.Println(err)
+		return CheckResult{}, err
+	}
+
+	contents, err := os.ReadFile(input.OutputPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return CheckResult{}, errors.New("exited without writing its output")
+	} else if err != nil {
+		log.Println(err)
+		return CheckResult{}, err
+	}
+
+	var output PluginOutput
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&output)
+	if err != nil {
+		log.Println(err)
+		return CheckResult{}, fmt.Errorf("malformed output: %w", err)
+	}
+	for _, line := range output.Logs {
+		log.Printf("[%s] %s", check.Name, line)
+	}
+
+	return output.result(check.MaxScore)
+}
+
+// result validates the output against the check it's for.
+func (o PluginOutput) result(maxScore float64) (CheckResult, error) {
+	if o.ProtocolVersion != PLUGIN_PROTOCOL_VERSION {
+		return CheckResult{}, fmt.Errorf("speaks protocol version %d, not %d", o.ProtocolVersion, PLUGIN_PROTOCOL_VERSION)
+	}
+	if len(o.Tests) == 0 {
+		return CheckResult{}, errors.New("reported no tests")
+	}
+
+	// Scores are compared with some slack for plugins that add up
+	// fractional points.
+	const epsilon = 1e-6
+
+	var score, testMaxScore float64
+	for i, test := range o.Tests {
+		if test.Name == "" {
+			return CheckResult{}, fmt.Errorf("test %d has no name", i)
+		}
+		if test.MaxScore < 0 || test.Score < 0 || test.Score > test.MaxScore+epsilon {
+			return CheckResult{}, fmt.Errorf("test %q scored %v out of %v", test.Name, test.Score, test.MaxScore)
+		}
+		score += test.Score
+		testMaxScore += test.MaxScore
+	}
+	if math.Abs(testMaxScore-maxScore) > epsilon {
+		return CheckResult{}, fmt.Errorf("tests are worth %v points, but the check is worth %v", testMaxScore, maxScore)
+	}
+
+	if o.Score != nil {
+		score = *o.Score
+	}
+	if score < 0 || score > maxScore+epsilon {
+		return CheckResult{}, fmt.Errorf("scored %v out of %v", score, maxScore)
+	}
+
+	return CheckResult{Score: score, Tests: o.Tests}, nil
+}
+
+func logPluginOutput(check string, stream string, output *limitedBuffer) {
+	if output.Len() == 0 {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(output.String(), "\n"), "\n") {
+		log.Printf("[%s %s] %s", check, stream, line)
+	}
+	if output.truncated {
+		log.Printf("[%s %s] (truncated after %d bytes)", check, stream, output.limit)
+	}
+}
+
+// A limitedBuffer keeps the first limit bytes written to it and discards
+// the rest, so a runaway plugin can't exhaust memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.Buffer.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,373 @@
+// Package posture checks how a student's domain is served over the network:
+// that it resolves, that its TLS certificate is the one ACM issued for it,
+// and that HTTP is redirected to HTTPS through CloudFront with HSTS set.
+package posture
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"infracourse.cloud/a2-grader/grader"
+	"infracourse.cloud/a2-grader/grader/yoctogram"
+)
+
+// HSTS_MIN_AGE is the shortest Strict-Transport-Security max-age accepted,
+// a year, which is what CloudFront's managed security headers policy sets.
+const HSTS_MIN_AGE = 365 * 24 * time.Hour
+
+// CERTIFICATE_ISSUER is the organization that issues ACM certificates.
+const CERTIFICATE_ISSUER = "Amazon"
+
+const (
+	RESOLVES    = "Domain resolves"
+	CERTIFICATE = "TLS certificate is valid for the domain"
+	ACM         = "TLS certificate matches the ACM certificate"
+	REDIRECT    = "HTTP redirects to HTTPS"
+	HSTS        = "HTTPS responses set Strict-Transport-Security"
+	CLOUDFRONT  = "Responses are served through CloudFront"
+)
+
+// A Resolver looks up a host's addresses. *net.Resolver is one.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// A StubResolver resolves hosts from a fixed table, such as to point the
+// student's domain at a local server.
+type StubResolver map[string][]string
+
+func (r StubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+// A Prober checks the posture of one host.
+type Prober struct {
+	// Host is the name the deployment is served at, and Domain the one its
+	// certificate was issued for, which Host is under.
+	Host   string
+	Domain string
+
+	Resolver Resolver
+
+	// RootCAs verify the certificate chain. If nil, the system's are used.
+	RootCAs *x509.CertPool
+
+	// Issuer is the organization that must have issued the certificate, or
+	// empty to accept any.
+	Issuer string
+
+	// HTTPPort and HTTPSPort are where the host is dialed for each scheme.
+	HTTPPort  string
+	HTTPSPort string
+}
+
+// NewProber probes host as it's served on the internet. The host's parent
+// domain is expected to be what its certificate was issued for.
+func NewProber(host string) *Prober {
+	domain := host
+	if _, parent, ok := strings.Cut(host, "."); ok {
+		domain = parent
+	}
+	return &Prober{
+		Host:      host,
+		Domain:    domain,
+		Resolver:  net.DefaultResolver,
+		Issuer:    CERTIFICATE_ISSUER,
+		HTTPPort:  "80",
+		HTTPSPort: "443",
+	}
+}
+
+// probe is the state shared between a probe's steps.
+type probe struct {
+	*Prober
+	addrs []string
+	chain []*x509.Certificate
+	https *http.Response
+}
+
+// Probe runs each posture test, worth points apiece.
+func (p *Prober) Probe(ctx context.Context, points float64) ([]grader.StepResult, error) {
+	s := &probe{Prober: p}
+	results, err := grader.RunSteps(ctx, s.steps(points))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	for _, result := range results {
+		log.Printf("posture: %s: %s in %v", result.Name, result.Outcome(), result.Duration.Round(time.Millisecond))
+	}
+	return results, nil
+}
+
+func (s *probe) steps(points float64) []grader.Step {
+	steps := []grader.Step{
+		{Name: RESOLVES, Run: s.resolve},
+		{Name: CERTIFICATE, Needs: []string{RESOLVES}, Run: s.certificate},
+		{Name: ACM, Needs: []string{CERTIFICATE}, Run: s.acm},
+		{Name: REDIRECT, Needs: []string{RESOLVES}, Run: s.redirect},
+		{Name: HSTS, Needs: []string{CERTIFICATE}, Run: s.hsts},
+		{Name: CLOUDFRONT, Needs: []string{CERTIFICATE}, Run: s.cloudfront},
+	}
+	for i := range steps {
+		steps[i].Points = points
+	}
+	return steps
+}
+
+func (s *probe) resolve(ctx context.Context) error {
+	addrs, err := s.Resolver.LookupHost(ctx, s.Host)
+	if err != nil {
+		return fmt.Errorf("Couldn't resolve %s: %v", s.Host, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("%s has no addresses", s.Host)
+	}
+	s.addrs = addrs
+	return nil
+}
+
+// dial connects to the resolved host rather than resolving it again, so
+// that every test sees the same addresses.
+func (s *probe) dial(ctx context.Context, network string, addr string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	switch port {
+	case "80":
+		port = s.HTTPPort
+	case "443":
+		port = s.HTTPSPort
+	}
+
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+	var errs []error
+	for _, ip := range s.addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (s *probe) certificate(ctx context.Context) error {
+	conn, err := s.dial(ctx, "tcp", net.JoinHostPort(s.Host, "443"))
+	if err != nil {
+		return fmt.Errorf("Couldn't connect to %s over HTTPS: %v", s.Host, err)
+	}
+	defer conn.Close()
+
+	// The chain is verified separately so that a bad one can be reported
+	// more helpfully than as a failed handshake.
+	client := tls.Client(conn, &tls.Config{
+		ServerName:         s.Host,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+	})
+	err = client.HandshakeContext(ctx)
+	if err != nil {
+		return fmt.Errorf("TLS handshake with %s failed: %v", s.Host, err)
+	}
+	s.chain = client.ConnectionState().PeerCertificates
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range s.chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = s.chain[0].Verify(x509.VerifyOptions{
+		DNSName:       s.Host,
+		Roots:         s.RootCAs,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return fmt.Errorf("The certificate served for %s isn't valid: %v", s.Host, err)
+	}
+	return nil
+}
+
+// acm checks the certificate against what the ACM rules expect: issued for
+// the domain, with wildcard alternative names under it.
+func (s *probe) acm(ctx context.Context) error {
+	leaf := s.chain[0]
+	if s.Issuer != "" && !contains(leaf.Issuer.Organization, s.Issuer) {
+		return fmt.Errorf("The certificate was issued by %q rather than by ACM", leaf.Issuer.String())
+	}
+
+	if !contains(leaf.DNSNames, s.Domain) {
+		return fmt.Errorf("The certificate isn't issued for %s; its names are %s", s.Domain, strings.Join(leaf.DNSNames, ", "))
+	}
+	wildcards := 0
+	for _, name := range leaf.DNSNames {
+		if name == s.Domain {
+			continue
+		}
+		if !strings.HasPrefix(name, "*.") || !strings.HasSuffix(name, "."+s.Domain) {
+			return fmt.Errorf("The certificate's alternative name %s isn't a wildcard under %s", name, s.Domain)
+		}
+		wildcards++
+	}
+	if wildcards == 0 {
+		return fmt.Errorf("The certificate has no wildcard alternative names under %s", s.Domain)
+	}
+	return nil
+}
+
+// client makes requests to the resolved host without following redirects.
+func (s *probe) client() *http.Client {
+	return &http.Client{
+		Timeout: grader.HTTPClient.Timeout,
+		Transport: &http.Transport{
+			DialContext:         s.dial,
+			TLSClientConfig:     &tls.Config{RootCAs: s.RootCAs, MinVersion: tls.VersionTLS12},
+			TLSHandshakeTimeout: 15 * time.Second,
+			DisableKeepAlives:   true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (s *probe) get(ctx context.Context, scheme string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", scheme+"://"+s.Host+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Requesting %s failed: %v", req.URL, err)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func (s *probe) redirect(ctx context.Context) error {
+	resp, err := s.get(ctx, "http")
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("Expected http://%s/ to redirect to HTTPS, but got status code %d", s.Host, resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || location.Scheme != "https" || location.Hostname() != s.Host {
+		return fmt.Errorf("Expected http://%s/ to redirect to https://%s/, but it redirects to %q", s.Host, s.Host, resp.Header.Get("Location"))
+	}
+	return nil
+}
+
+// httpsResponse is the response to an HTTPS request for the front page, shared by
+// the tests of its headers.
+func (s *probe) httpsResponse(ctx context.Context) (*http.Response, error) {
+	if s.https != nil {
+		return s.https, nil
+	}
+	resp, err := s.get(ctx, "https")
+	if err != nil {
+		return nil, err
+	}
+	s.https = resp
+	return resp, nil
+}
+
+func (s *probe) hsts(ctx context.Context) error {
+	resp, err := s.httpsResponse(ctx)
+	if err != nil {
+		return err
+	}
+
+	header := resp.Header.Get("Strict-Transport-Security")
+	if header == "" {
+		return errors.New("HTTPS responses have no Strict-Transport-Security header")
+	}
+	for _, directive := range strings.Split(header, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+		if err != nil {
+			return fmt.Errorf("The Strict-Transport-Security header %q has a malformed max-age", header)
+		}
+		if time.Duration(seconds)*time.Second < HSTS_MIN_AGE {
+			return fmt.Errorf("The Strict-Transport-Security max-age is %d seconds, but should be at least %d", seconds, int64(HSTS_MIN_AGE.Seconds()))
+		}
+		return nil
+	}
+	return fmt.Errorf("The Strict-Transport-Security header %q has no max-age", header)
+}
+
+func (s *probe) cloudfront(ctx context.Context) error {
+	resp, err := s.httpsResponse(ctx)
+	if err != nil {
+		return err
+	}
+	if resp.Header.Get("X-Amz-Cf-Id") == "" && !strings.Contains(strings.ToLower(resp.Header.Get("Via")), "cloudfront") {
+		return fmt.Errorf("https://%s/ isn't served through CloudFront: its response has neither CloudFront's Via nor its X-Amz-Cf-Id header", s.Host)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Check probes the submitter's domain, worth points in all, split evenly
+// between its tests. The domain is baseURL's host if set, and otherwise that
+// of the Yoctogram deployment for the SUNet ID in the submission's SUNET
+// file.
+func Check(name string, points float64, baseURL string) grader.Check {
+	pointsPerTest := points / float64(len((&probe{}).steps(0)))
+
+	return grader.Check{
+		Name:     name,
+		MaxScore: points,
+		Run: func(ctx context.Context, submission grader.Submission) (grader.CheckResult, error) {
+			target := baseURL
+			if target == "" {
+				sunet, err := submission.ReadFile("SUNET")
+				if err != nil {
+					log.Println(err)
+					return grader.CheckResult{}, err
+				}
+				target = yoctogram.URL(sunet)
+			}
+			parsed, err := url.Parse(target)
+			if err != nil {
+				log.Println(err)
+				return grader.CheckResult{}, err
+			}
+
+			results, err := NewProber(parsed.Hostname()).Probe(ctx, pointsPerTest)
+			if err != nil {
+				return grader.CheckResult{}, err
+			}
+			return grader.StepsResult(results), nil
+		},
+	}
+}
@@ -0,0 +1,233 @@
+package posture
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"infracourse.cloud/a2-grader/grader"
+)
+
+const (
+	HOST   = "yoctogram.alice.infracourse.cloud"
+	DOMAIN = "alice.infracourse.cloud"
+)
+
+// A deployment is a local stand-in for a student's domain: an HTTPS server
+// with a certificate from a test CA, and a plain HTTP server.
+type deployment struct {
+	// names are what the certificate is issued for, and issuer the
+	// organization that issued it.
+	names  []string
+	issuer string
+
+	// https answers HTTPS requests, and http plain ones.
+	https http.HandlerFunc
+	http  http.HandlerFunc
+}
+
+// correct is served the way CloudFront serves a correct deployment.
+func correct() deployment {
+	return deployment{
+		names:  []string{DOMAIN, "*." + DOMAIN},
+		issuer: CERTIFICATE_ISSUER,
+		https: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+			w.Header().Set("Via", "1.1 abc.cloudfront.net (CloudFront)")
+			w.Header().Set("X-Amz-Cf-Id", "id")
+		},
+		http: func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "https://"+r.Host+r.URL.Path, http.StatusMovedPermanently)
+		},
+	}
+}
+
+// prober starts d and returns a Prober pointed at it.
+func (d deployment) prober(t *testing.T) *Prober {
+	t.Helper()
+	ca, caKey := newCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{Organization: []string{d.issuer}, CommonName: "Test Root"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	leaf, leafKey := newCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: d.names[0]},
+		DNSNames:    d.names,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+
+	https := httptest.NewUnstartedServer(d.https)
+	https.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.Raw, ca.Raw}, PrivateKey: leafKey}}}
+	https.StartTLS()
+	t.Cleanup(https.Close)
+	plain := httptest.NewServer(d.http)
+	t.Cleanup(plain.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return &Prober{
+		Host:      HOST,
+		Domain:    DOMAIN,
+		Resolver:  StubResolver{HOST: {"127.0.0.1"}},
+		RootCAs:   roots,
+		Issuer:    CERTIFICATE_ISSUER,
+		HTTPPort:  port(t, plain.URL),
+		HTTPSPort: port(t, https.URL),
+	}
+}
+
+var serial int64
+
+func newCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	template.SerialNumber = big.NewInt(serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func port(t *testing.T, rawURL string) string {
+	t.Helper()
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, err := net.SplitHostPort(parsed.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func probeSteps(t *testing.T, p *Prober) map[string]grader.StepResult {
+	t.Helper()
+	results, err := p.Probe(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := map[string]grader.StepResult{}
+	for _, result := range results {
+		steps[result.Name] = result
+	}
+	return steps
+}
+
+// expect checks that exactly the steps in failed failed, and that those in
+// skipped were skipped.
+func expect(t *testing.T, steps map[string]grader.StepResult, failed []string, skipped []string) {
+	t.Helper()
+	want := map[string]string{}
+	for _, name := range failed {
+		want[name] = "failed"
+	}
+	for _, name := range skipped {
+		want[name] = "skipped"
+	}
+	for name, step := range steps {
+		got := "passed"
+		if step.SkippedBecause != "" {
+			got = "skipped"
+		} else if step.Err != nil {
+			got = "failed"
+		}
+		wanted := want[name]
+		if wanted == "" {
+			wanted = "passed"
+		}
+		if got != wanted {
+			t.Errorf("%s: %s", name, step.Outcome())
+		}
+	}
+}
+
+func TestCorrectDeploymentPasses(t *testing.T) {
+	steps := probeSteps(t, correct().prober(t))
+	expect(t, steps, nil, nil)
+	if score := grader.StepsResult(mapValues(steps)).Score; score != 12 {
+		t.Errorf("scored %v, want 12", score)
+	}
+}
+
+func TestUnresolvableDomainSkipsTheRest(t *testing.T) {
+	p := correct().prober(t)
+	p.Resolver = StubResolver{}
+	expect(t, probeSteps(t, p), []string{RESOLVES}, []string{CERTIFICATE, ACM, REDIRECT, HSTS, CLOUDFRONT})
+}
+
+func TestUntrustedCertificateFails(t *testing.T) {
+	p := correct().prober(t)
+	p.RootCAs = x509.NewCertPool()
+	expect(t, probeSteps(t, p), []string{CERTIFICATE}, []string{ACM, HSTS, CLOUDFRONT})
+}
+
+func TestCertificateNotFromACMFails(t *testing.T) {
+	d := correct()
+	d.issuer = "Let's Encrypt"
+	expect(t, probeSteps(t, d.prober(t)), []string{ACM}, nil)
+}
+
+func TestCertificateWithoutWildcardsFails(t *testing.T) {
+	d := correct()
+	d.names = []string{HOST, DOMAIN}
+	expect(t, probeSteps(t, d.prober(t)), []string{ACM}, nil)
+}
+
+func TestMissingRedirectFails(t *testing.T) {
+	d := correct()
+	d.http = func(w http.ResponseWriter, r *http.Request) {}
+	expect(t, probeSteps(t, d.prober(t)), []string{REDIRECT}, nil)
+}
+
+func TestShortHSTSFails(t *testing.T) {
+	d := correct()
+	d.https = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=300")
+		w.Header().Set("Via", "1.1 abc.cloudfront.net (CloudFront)")
+	}
+	expect(t, probeSteps(t, d.prober(t)), []string{HSTS}, nil)
+}
+
+func TestServedWithoutCloudFrontFails(t *testing.T) {
+	d := correct()
+	d.https = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+	}
+	expect(t, probeSteps(t, d.prober(t)), []string{CLOUDFRONT}, nil)
+}
+
+func mapValues(steps map[string]grader.StepResult) []grader.StepResult {
+	values := make([]grader.StepResult, 0, len(steps))
+	for _, step := range steps {
+		values = append(values, step)
+	}
+	return values
+}
@@ -0,0 +1,271 @@
+package grader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/bundle"
+	"github.com/open-policy-agent/opa/loader"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// RULES_KEY_PATH is where the image keeps the public key that rule bundles
+// must be signed with.
+const RULES_KEY_PATH = "/autograder/rules.pub.pem"
+
+// RULES_KEY_ID is the key ID rule bundles are signed under. The signature
+// must name it, so a bundle signed with some other key is refused even if
+// that key happens to verify.
+const RULES_KEY_ID = "infracourse-rules"
+
+// A RuleBundle is a loaded rule bundle along with what identifies the exact
+// rules a submission was graded with.
+type RuleBundle struct {
+	*bundle.Bundle
+
+	// Digest is a SHA-256 over the bundle's files, so it changes whenever
+	// any rule does.
+	Digest string
+
+	// Verified is set when the bundle's signature was checked, which is
+	// always the case unless unsigned rules were explicitly allowed.
+	Verified bool
+}
+
+// Revision is the revision declared in the bundle's .manifest, if any,
+// falling back to its digest.
+func (b RuleBundle) Revision() string {
+	if b.Manifest.Revision != "" {
+		return b.Manifest.Revision
+	}
+	return b.Digest
+}
+
+// getRuleBundle loads the bundle at rulesPath within the rules embedded in
+// the orchestrator, or the bundle in options.RulesDir on disk if set. Either
+// way the bundle must carry a .signatures.json made with the key at
+// options.RulesKeyPath; a bundle that is unsigned, signed with another key,
+// or has any file added, removed or changed since signing is refused.
+func getRuleBundle(options Options, embedded fs.FS, rulesPath string) (RuleBundle, error) {
+	var verification *bundle.VerificationConfig
+	if !options.UnsignedRules {
+		var err error
+		verification, err = ruleVerification(options.RulesKeyPath)
+		if err != nil {
+			log.Println(err)
+			return RuleBundle{}, err
+		}
+	}
+
+	if options.RulesDir != "" {
+		return loadRuleBundle(os.DirFS(options.RulesDir), ".", verification)
+	}
+
+	if embedded == nil {
+		return RuleBundle{}, errors.New("the orchestrator was built without rules; use -rules")
+	}
+	return loadRuleBundle(embedded, rulesPath, verification)
+}
+
+func ruleVerification(keyPath string) (*bundle.VerificationConfig, error) {
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		log.Println(err)
+		return nil, fmt.Errorf("reading the rules public key: %w", err)
+	}
+
+	keys := map[string]*bundle.KeyConfig{
+		RULES_KEY_ID: {Key: string(key), Algorithm: "RS256"},
+	}
+	return bundle.NewVerificationConfig(keys, RULES_KEY_ID, "", nil), nil
+}
+
+// loadRuleBundle loads the bundle in dir, verifying its signature unless
+// verification is nil.
+func loadRuleBundle(fsys fs.FS, dir string, verification *bundle.VerificationConfig) (RuleBundle, error) {
+	digest, err := bundleDigest(fsys, dir)
+	if err != nil {
+		log.Println(err)
+		return RuleBundle{}, err
+	}
+
+	// Annotations must be kept so rules can be listed in the rubric and can
+	// refer to their own metadata through rego.metadata.rule().
+	bundleLoader := loader.NewFileLoader().WithFS(fsys).WithProcessAnnotation(true)
+	if verification != nil {
+		bundleLoader = bundleLoader.WithBundleVerificationConfig(verification)
+	} else {
+		bundleLoader = bundleLoader.WithSkipBundleVerification(true)
+	}
+
+	b, err := bundleLoader.AsBundle(dir)
+	if err != nil {
+		log.Println(err)
+		return RuleBundle{}, err
+	}
+
+	return RuleBundle{Bundle: b, Digest: digest, Verified: verification != nil}, nil
+}
+
+func bundleDigest(fsys fs.FS, dir string) (string, error) {
+	hash := sha256.New()
+	files := 0
+	// WalkDir visits files in lexical order, so the digest is stable.
+	err := fs.WalkDir(fsys, dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		contents, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(hash, "%s\x00%d\x00", strings.TrimPrefix(path, dir+"/"), len(contents))
+		hash.Write(contents)
+		files++
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if files == 0 {
+		return "", fmt.Errorf("rule bundle %s is empty", dir)
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func evalRules(ctx context.Context, rules RuleBundle, resources map[string]interface{}) ([]Violation, error) {
+	query, err := rego.New(
+		rego.ParsedBundle("rules", rules.Bundle),
+		rego.Query("data.rules.main"),
+	).PrepareForEval(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	results, err := query.Eval(ctx, rego.EvalInput(resources))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, errors.New("rule evaluation returned no results")
+	}
+
+	return parseViolations(results[0].Expressions[0].Value)
+}
+
+// A Rule is a check declared in the rule bundle with a METADATA annotation:
+//
+//	# METADATA
+//	# title: Only one VPC is defined
+//	# description: Hint shown to students when the rule fails
+//	# custom:
+//	#   id: vpc-count
+//	#   points: 2
+//	#   category: network
+//	#   resource_types: ["AWS::EC2::VPC"]
+//	fail contains msg if {
+//		...
+//		msg := {"id": rego.metadata.rule().custom.id, "message": "..."}
+//	}
+//
+// Rules without a custom id are still evaluated but can't be listed in the
+// rubric, since their violations can't be traced back to them. A rule that
+// lists resource_types is only listed as passing when the template has a
+// resource of one of those types, since otherwise it had nothing to check.
+type Rule struct {
+	ID            string
+	Title         string
+	Description   string
+	Points        *float64
+	Category      string
+	ResourceTypes []string
+}
+
+type ruleCustom struct {
+	ID            string   `json:"id"`
+	Points        *float64 `json:"points"`
+	Category      string   `json:"category"`
+	ResourceTypes []string `json:"resource_types"`
+}
+
+// ruleCatalog lists the annotated rules in the bundle, ordered by file and
+// position.
+func ruleCatalog(rules RuleBundle) ([]Rule, error) {
+	modules := make([]bundle.ModuleFile, len(rules.Modules))
+	copy(modules, rules.Modules)
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Path < modules[j].Path })
+
+	catalog := []Rule{}
+	seen := map[string]string{}
+	for _, module := range modules {
+		for _, annotation := range module.Parsed.Annotations {
+			if annotation.Scope != "rule" || annotation.Custom == nil {
+				continue
+			}
+
+			// Custom holds whatever YAML decoded to, so round-trip it through
+			// JSON to pick out typed fields.
+			encoded, err := json.Marshal(annotation.Custom)
+			if err != nil {
+				return nil, err
+			}
+			var custom ruleCustom
+			err = json.Unmarshal(encoded, &custom)
+			if err != nil {
+				return nil, fmt.Errorf("%s: malformed rule metadata: %w", annotation.Location, err)
+			}
+			if custom.ID == "" {
+				continue
+			}
+			if location, ok := seen[custom.ID]; ok {
+				return nil, fmt.Errorf("%s: rule id %q is already used at %s", annotation.Location, custom.ID, location)
+			}
+			seen[custom.ID] = annotation.Location.String()
+
+			title := annotation.Title
+			if title == "" {
+				title = custom.ID
+			}
+
+			catalog = append(catalog, Rule{
+				ID:            custom.ID,
+				Title:         title,
+				Description:   annotation.Description,
+				Points:        custom.Points,
+				Category:      custom.Category,
+				ResourceTypes: custom.ResourceTypes,
+			})
+		}
+	}
+
+	return catalog, nil
+}
+
+// withRule fills in anything the violation leaves unset from the metadata of
+// the rule that raised it.
+func (v Violation) withRule(rule Rule) Violation {
+	if v.Points == nil {
+		v.Points = rule.Points
+	}
+	if v.Category == "" {
+		v.Category = rule.Category
+	}
+	if v.Hint == "" {
+		v.Hint = rule.Description
+	}
+	return v
+}
@@ -0,0 +1,143 @@
+package slo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Sample is the outcome of one request of a burst.
+type Sample struct {
+	// Latency is the time until the whole response was read.
+	Latency    time.Duration `json:"latency"`
+	StatusCode int           `json:"status_code,omitempty"`
+
+	// Err is why the request got no response, if it didn't.
+	Err string `json:"error,omitempty"`
+}
+
+// Failed is whether the request counts against availability: it got no
+// response in time, or a server error. Any other response, even a client
+// error, means the service answered.
+func (s Sample) Failed() bool {
+	return s.Err != "" || s.StatusCode >= 500
+}
+
+// Burst makes config.Requests requests of target, config.Concurrency at a
+// time, after config.Warmup uncounted ones.
+func (c Config) Burst(ctx context.Context, client *http.Client, baseURL string, target Target) []Sample {
+	url := strings.TrimRight(baseURL, "/") + target.Path
+	for i := 0; i < c.Warmup && ctx.Err() == nil; i++ {
+		c.request(ctx, client, target.Method, url)
+	}
+
+	samples := make([]Sample, c.Requests)
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < c.Concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				samples[i] = c.request(ctx, client, target.Method, url)
+			}
+		}()
+	}
+	for i := range samples {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	return samples
+}
+
+func (c Config) request(ctx context.Context, client *http.Client, method string, url string) Sample {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.RequestTimeout))
+	defer cancel()
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return Sample{Err: err.Error()}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Sample{Latency: time.Since(start), Err: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(io.Discard, resp.Body)
+	sample := Sample{Latency: time.Since(start), StatusCode: resp.StatusCode}
+	if err != nil {
+		sample.Err = err.Error()
+	}
+	return sample
+}
+
+// Stats summarize a burst.
+type Stats struct {
+	Requests int
+	Errors   int
+
+	// P50 and P95 are latency percentiles, counting a request that got no
+	// response as UNANSWERED, slower than any that did.
+	P50 time.Duration
+	P95 time.Duration
+
+	// FirstError describes the first failed request, if any did.
+	FirstError string
+}
+
+// UNANSWERED is the latency percentiles count a request without a response
+// as having.
+const UNANSWERED = time.Duration(math.MaxInt64)
+
+func Summarize(samples []Sample) Stats {
+	stats := Stats{Requests: len(samples)}
+	latencies := make([]time.Duration, 0, len(samples))
+	for _, sample := range samples {
+		if sample.Err != "" {
+			latencies = append(latencies, UNANSWERED)
+		} else {
+			latencies = append(latencies, sample.Latency)
+		}
+		if !sample.Failed() {
+			continue
+		}
+		stats.Errors++
+		if stats.FirstError == "" {
+			stats.FirstError = sample.Err
+			if stats.FirstError == "" {
+				stats.FirstError = fmt.Sprintf("status code %d", sample.StatusCode)
+			}
+		}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.P50 = percentile(latencies, 50)
+	stats.P95 = percentile(latencies, 95)
+	return stats
+}
+
+// ErrorRate is the fraction of requests that failed.
+func (s Stats) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+// percentile is the nearest-rank percentile of sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
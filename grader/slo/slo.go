@@ -0,0 +1,285 @@
+// Package slo checks a student's deployment against service level
+// objectives: it sends each of its endpoints a short burst of requests and
+// judges their latency percentiles and error rate, which is where an
+// undersized Fargate service or an uncached CloudFront distribution shows.
+package slo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"infracourse.cloud/a2-grader/grader"
+	"infracourse.cloud/a2-grader/grader/yoctogram"
+)
+
+// Defaults for a config that leaves them out.
+const (
+	DEFAULT_REQUESTS        = 50
+	DEFAULT_CONCURRENCY     = 5
+	DEFAULT_WARMUP          = 2
+	DEFAULT_REQUEST_TIMEOUT = 10 * time.Second
+)
+
+// MAX_REQUESTS bounds each burst however it's configured, so that grading
+// never turns into a load test of a student's AWS account.
+const MAX_REQUESTS = 500
+
+// A Config lists the endpoints to probe and how hard.
+type Config struct {
+	// Requests is how many requests each endpoint gets, Concurrency how
+	// many at a time, and Warmup how many it gets first that don't count,
+	// so that opening connections and filling caches don't.
+	Requests    int `json:"requests,omitempty"`
+	Concurrency int `json:"concurrency,omitempty"`
+	Warmup      int `json:"warmup,omitempty"`
+
+	// RequestTimeout is how long a request has before it counts as failed.
+	RequestTimeout grader.Duration `json:"request_timeout,omitempty"`
+
+	Targets []Target `json:"targets"`
+}
+
+// A Target is an endpoint and its objectives.
+type Target struct {
+	Name   string `json:"name"`
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path"`
+
+	Objectives Objectives `json:"objectives"`
+}
+
+// Objectives are what a burst must meet. Each one set is a test.
+type Objectives struct {
+	P50 grader.Duration `json:"p50,omitempty"`
+	P95 grader.Duration `json:"p95,omitempty"`
+
+	// ErrorRate is the largest fraction of requests that may fail.
+	ErrorRate *float64 `json:"error_rate,omitempty"`
+}
+
+func (o Objectives) count() int {
+	count := 0
+	if o.P50 != 0 {
+		count++
+	}
+	if o.P95 != 0 {
+		count++
+	}
+	if o.ErrorRate != nil {
+		count++
+	}
+	return count
+}
+
+func LoadConfig(path string) (Config, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		log.Println(err)
+		return Config{}, err
+	}
+
+	config := Config{
+		Requests:       DEFAULT_REQUESTS,
+		Concurrency:    DEFAULT_CONCURRENCY,
+		Warmup:         DEFAULT_WARMUP,
+		RequestTimeout: grader.Duration(DEFAULT_REQUEST_TIMEOUT),
+	}
+	err = yaml.UnmarshalStrict(contents, &config)
+	if err != nil {
+		log.Println(err)
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if config.Requests <= 0 || config.Requests > MAX_REQUESTS {
+		return Config{}, fmt.Errorf("%s: requests must be between 1 and %d", path, MAX_REQUESTS)
+	}
+	if config.Concurrency <= 0 || config.Warmup < 0 {
+		return Config{}, fmt.Errorf("%s: concurrency must be positive and warmup not negative", path)
+	}
+	config.Concurrency = min(config.Concurrency, config.Requests)
+	if len(config.Targets) == 0 {
+		return Config{}, fmt.Errorf("%s: no targets", path)
+	}
+
+	names := map[string]bool{}
+	for i, target := range config.Targets {
+		if target.Name == "" || names[target.Name] {
+			return Config{}, fmt.Errorf("%s: target %d needs a name of its own", path, i)
+		}
+		names[target.Name] = true
+		if !strings.HasPrefix(target.Path, "/") {
+			return Config{}, fmt.Errorf("%s: target %q: path must start with /", path, target.Name)
+		}
+		if target.Objectives.count() == 0 {
+			return Config{}, fmt.Errorf("%s: target %q has no objectives", path, target.Name)
+		}
+		if target.Method == "" {
+			config.Targets[i].Method = http.MethodGet
+		}
+	}
+	return config, nil
+}
+
+// A Recording is the samples of a run of the check, by target name, so
+// that they can be judged again against revised objectives.
+type Recording struct {
+	RecordedAt time.Time           `json:"recorded_at"`
+	Samples    map[string][]Sample `json:"samples"`
+}
+
+// Probe bursts each of the config's targets at baseURL in turn.
+func (c Config) Probe(ctx context.Context, client *http.Client, baseURL string) Recording {
+	recording := Recording{RecordedAt: time.Now().UTC(), Samples: map[string][]Sample{}}
+	for _, target := range c.Targets {
+		samples := c.Burst(ctx, client, baseURL, target)
+		stats := Summarize(samples)
+		log.Printf("slo: %s: %d of %d requests failed, p50 %s, p95 %s", target.Name, stats.Errors, stats.Requests, formatLatency(stats.P50), formatLatency(stats.P95))
+		recording.Samples[target.Name] = samples
+	}
+	return recording
+}
+
+func formatLatency(latency time.Duration) string {
+	if latency == UNANSWERED {
+		return "no response"
+	}
+	return latency.Round(time.Millisecond).String()
+}
+
+// Judge scores a recording against the config's objectives, splitting
+// points evenly between them.
+func (c Config) Judge(recording Recording, points float64) grader.CheckResult {
+	objectives := 0
+	for _, target := range c.Targets {
+		objectives += target.Objectives.count()
+	}
+	each := points / float64(objectives)
+
+	var result grader.CheckResult
+	for _, target := range c.Targets {
+		stats := Summarize(recording.Samples[target.Name])
+		for _, test := range target.tests(stats) {
+			test.MaxScore = each
+			if test.Output == "" {
+				test.Score = each
+				test.Output = "Pass"
+			}
+			result.Score += test.Score
+			result.Tests = append(result.Tests, test)
+		}
+	}
+	return result
+}
+
+// tests judges a target's stats, leaving the output of those that pass
+// empty.
+func (t Target) tests(stats Stats) []grader.GradescopeTest {
+	var tests []grader.GradescopeTest
+	latency := func(name string, got time.Duration, objective grader.Duration) {
+		test := grader.GradescopeTest{Name: fmt.Sprintf("%s %s latency is at most %v", t.Name, name, objective)}
+		switch {
+		case stats.Requests == 0:
+			test.Output = "No requests were made."
+		case got == UNANSWERED:
+			test.Output = fmt.Sprintf("Too many of the %d requests got no response in time for there to be a %s latency; the first failed with %s.", stats.Requests, name, stats.FirstError)
+		case got > time.Duration(objective):
+			test.Output = fmt.Sprintf("Over %d requests, the %s latency was %v.", stats.Requests, name, got.Round(time.Millisecond))
+		}
+		tests = append(tests, test)
+	}
+	if t.Objectives.P50 != 0 {
+		latency("p50", stats.P50, t.Objectives.P50)
+	}
+	if t.Objectives.P95 != 0 {
+		latency("p95", stats.P95, t.Objectives.P95)
+	}
+
+	if t.Objectives.ErrorRate != nil {
+		objective := *t.Objectives.ErrorRate
+		test := grader.GradescopeTest{Name: fmt.Sprintf("%s error rate is at most %.1f%%", t.Name, objective*100)}
+		switch {
+		case stats.Requests == 0:
+			test.Output = "No requests were made."
+		case stats.ErrorRate() > objective:
+			test.Output = fmt.Sprintf("%d of %d requests failed (%.1f%%); the first with %s.", stats.Errors, stats.Requests, stats.ErrorRate()*100, stats.FirstError)
+		}
+		tests = append(tests, test)
+	}
+	return tests
+}
+
+// Check bursts the endpoints in options.SLOConfigPath, worth points in
+// all. The deployment is at options.YoctogramURL if set, and otherwise at
+// the URL for the SUNet ID in the submission's SUNET file.
+//
+// The samples are recorded alongside the check's other recordings and in
+// results.json, and with options.Replay they're judged again rather than
+// taken afresh, from options.ReplayResults if set.
+func Check(name string, points float64, options grader.Options) grader.Check {
+	return grader.Check{
+		Name:     name,
+		MaxScore: points,
+		Run: func(ctx context.Context, submission grader.Submission) (grader.CheckResult, error) {
+			config, err := LoadConfig(options.SLOConfigPath)
+			if err != nil {
+				return grader.CheckResult{}, err
+			}
+
+			path := options.RecordingPath(name, ".json")
+			var recording Recording
+			if options.Replay && options.ReplayResults != "" {
+				err = options.LoadRecording(name, &recording)
+				if err != nil {
+					return grader.CheckResult{}, err
+				}
+			} else if options.Replay {
+				if path == "" {
+					return grader.CheckResult{}, errors.New("replaying needs the results.json or directory the samples were recorded to")
+				}
+				contents, err := os.ReadFile(path)
+				if err != nil {
+					log.Println(err)
+					return grader.CheckResult{}, err
+				}
+				err = json.Unmarshal(contents, &recording)
+				if err != nil {
+					return grader.CheckResult{}, fmt.Errorf("%s: %w", path, err)
+				}
+			} else {
+				baseURL := options.YoctogramURL
+				if baseURL == "" {
+					sunet, err := submission.ReadFile("SUNET")
+					if err != nil {
+						log.Println(err)
+						return grader.CheckResult{}, err
+					}
+					baseURL = yoctogram.URL(sunet)
+				}
+
+				recording = config.Probe(ctx, grader.HTTPClient, baseURL)
+				if path != "" {
+					// A failure to record shouldn't cost the student points.
+					contents, err := json.Marshal(recording)
+					if err == nil {
+						err = os.WriteFile(path, contents, 0644)
+					}
+					if err != nil {
+						log.Println(err)
+					}
+				}
+			}
+			result := config.Judge(recording, points)
+			result.Recording = recording
+			return result, nil
+		},
+	}
+}
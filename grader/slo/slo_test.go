@@ -0,0 +1,178 @@
+package slo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"infracourse.cloud/a2-grader/grader"
+)
+
+// deployment serves endpoints with latency and errors injected: / at once,
+// /slow after SLOW, /flaky with every fourth request a 503, and /hang not
+// until the request is given up on.
+func deployment(t *testing.T) *httptest.Server {
+	var flaky atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(SLOW)
+		case "/flaky":
+			if flaky.Add(1)%4 == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/hang":
+			<-r.Context().Done()
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+const SLOW = 100 * time.Millisecond
+
+func config(targets ...Target) Config {
+	return Config{
+		Requests:       20,
+		Concurrency:    4,
+		Warmup:         1,
+		RequestTimeout: grader.Duration(300 * time.Millisecond),
+		Targets:        targets,
+	}
+}
+
+func errorRate(rate float64) *float64 {
+	return &rate
+}
+
+// judge probes the deployment with c and returns the result, along with
+// whether each of its tests passed, by name.
+func judge(t *testing.T, c Config, points float64) (grader.CheckResult, map[string]bool) {
+	t.Helper()
+	recording := c.Probe(context.Background(), http.DefaultClient, deployment(t).URL)
+	result := c.Judge(recording, points)
+	passed := map[string]bool{}
+	for _, test := range result.Tests {
+		passed[test.Name] = test.Output == "Pass"
+	}
+	return result, passed
+}
+
+func TestFastEndpointMeetsItsObjectives(t *testing.T) {
+	result, passed := judge(t, config(Target{Name: "front page", Method: "GET", Path: "/", Objectives: Objectives{
+		P50:       grader.Duration(SLOW / 2),
+		P95:       grader.Duration(SLOW / 2),
+		ErrorRate: errorRate(0),
+	}}), 6)
+
+	for name, ok := range passed {
+		if !ok {
+			t.Errorf("%s failed", name)
+		}
+	}
+	if len(passed) != 3 || result.Score != 6 {
+		t.Errorf("scored %v in %d tests, want 6 in 3", result.Score, len(passed))
+	}
+}
+
+func TestInjectedLatencyFailsLatencyObjectives(t *testing.T) {
+	result, passed := judge(t, config(Target{Name: "api", Method: "GET", Path: "/slow", Objectives: Objectives{
+		P95:       grader.Duration(SLOW / 2),
+		ErrorRate: errorRate(0),
+	}}), 6)
+
+	for name, ok := range passed {
+		if want := !strings.Contains(name, "p95"); ok != want {
+			t.Errorf("%s: passed %v, want %v", name, ok, want)
+		}
+	}
+	if result.Score != 3 {
+		t.Errorf("scored %v, want 3", result.Score)
+	}
+}
+
+func TestServerErrorsFailErrorRate(t *testing.T) {
+	samples := config().Burst(context.Background(), http.DefaultClient, deployment(t).URL, Target{Method: "GET", Path: "/flaky"})
+	stats := Summarize(samples)
+	if stats.Errors == 0 || stats.FirstError != "status code 503" {
+		t.Errorf("%d errors, the first %q", stats.Errors, stats.FirstError)
+	}
+
+	_, passed := judge(t, config(Target{Name: "api", Method: "GET", Path: "/flaky", Objectives: Objectives{ErrorRate: errorRate(0.1)}}), 6)
+	if passed["api error rate is at most 10.0%"] {
+		t.Error("a quarter of requests failing met a 10% error rate")
+	}
+}
+
+func TestHangingEndpointCountsAsUnanswered(t *testing.T) {
+	c := config()
+	c.Requests, c.Warmup = 4, 0
+	stats := Summarize(c.Burst(context.Background(), http.DefaultClient, deployment(t).URL, Target{Method: "GET", Path: "/hang"}))
+	if stats.Errors != 4 || stats.P50 != UNANSWERED || stats.P95 != UNANSWERED {
+		t.Errorf("%d errors, p50 %v, p95 %v", stats.Errors, stats.P50, stats.P95)
+	}
+}
+
+func TestLoadConfigBoundsRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slo.yaml")
+	write := func(contents string) {
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("requests: 501\ntargets:\n  - name: front page\n    path: /\n    objectives:\n      p95: 1s\n")
+	if _, err := LoadConfig(path); err == nil {
+		t.Errorf("loaded a config of more than %d requests", MAX_REQUESTS)
+	}
+
+	write("targets:\n  - name: front page\n    path: /\n    objectives:\n      p95: 1s\n")
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Requests != DEFAULT_REQUESTS || c.Targets[0].Method != http.MethodGet {
+		t.Errorf("loaded %+v", c)
+	}
+}
+
+func TestReplaysSamplesFromResults(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "slo.yaml")
+	err := os.WriteFile(configPath, []byte("targets:\n  - name: api\n    path: /slow\n    objectives:\n      p95: 50ms\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := grader.Options{SLOConfigPath: configPath, YoctogramURL: deployment(t).URL}
+	recorded, err := Check("slo", 6, options).Run(context.Background(), grader.Submission{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The deployment is gone by the regrade, and only results.json is kept.
+	results := filepath.Join(dir, "results.json")
+	contents, err := json.Marshal(map[string]interface{}{"extra_data": map[string]interface{}{"recordings": map[string]interface{}{"slo": recorded.Recording}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(results, contents, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options = grader.Options{SLOConfigPath: configPath, YoctogramURL: "http://127.0.0.1:1", Replay: true, ReplayResults: results}
+	replayed, err := Check("slo", 6, options).Run(context.Background(), grader.Submission{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Score != recorded.Score || len(replayed.Tests) != len(recorded.Tests) || replayed.Tests[0].Output == "Pass" {
+		t.Errorf("replayed %+v, recorded %+v", replayed, recorded)
+	}
+}
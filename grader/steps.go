@@ -0,0 +1,126 @@
+package grader
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// A Step is one test of a check whose tests depend on each other, such as
+// logging in to an account before posting from it. A check's steps form a
+// graph: a step runs only if every step it needs passed, so a failure only
+// costs the steps downstream of it and independent branches still run.
+type Step struct {
+	Name   string
+	Points float64
+
+	// Needs names the steps that must pass for this one to run. They must
+	// come before it in the list of steps.
+	Needs []string
+
+	// SkippedCredit is the fraction of Points awarded when the step is
+	// skipped because a step it needs failed. It's usually zero, but can
+	// give partial credit for a step that couldn't be tested through no
+	// fault of its own.
+	SkippedCredit float64
+
+	Run func(ctx context.Context) error
+}
+
+type StepResult struct {
+	Name     string
+	Score    float64
+	MaxScore float64
+
+	// Err is why the step failed, or nil if it passed or was skipped.
+	Err error
+
+	// SkippedBecause names the failed step that kept this one from
+	// running, if it was skipped.
+	SkippedBecause string
+
+	Duration time.Duration
+}
+
+func (r StepResult) Passed() bool {
+	return r.Err == nil && r.SkippedBecause == ""
+}
+
+// Outcome describes the result for the log.
+func (r StepResult) Outcome() string {
+	switch {
+	case r.SkippedBecause != "":
+		return fmt.Sprintf("skipped because %q failed", r.SkippedBecause)
+	case r.Err != nil:
+		return fmt.Sprintf("failed (%v)", r.Err)
+	}
+	return "passed"
+}
+
+func (r StepResult) test() GradescopeTest {
+	test := GradescopeTest{
+		Score:    r.Score,
+		MaxScore: r.MaxScore,
+		Name:     r.Name,
+		Output:   "Pass",
+	}
+	if r.SkippedBecause != "" {
+		test.Output = fmt.Sprintf("Skipped because %q failed", r.SkippedBecause)
+	} else if r.Err != nil {
+		test.Output = r.Err.Error()
+	}
+	return test
+}
+
+// RunSteps runs steps in order, skipping those that need a step that didn't
+// pass. It only returns an error if the steps themselves are malformed.
+func RunSteps(ctx context.Context, steps []Step) ([]StepResult, error) {
+	results := make([]StepResult, 0, len(steps))
+	index := map[string]int{}
+	for _, step := range steps {
+		if _, ok := index[step.Name]; ok {
+			return nil, fmt.Errorf("step %q is declared twice", step.Name)
+		}
+
+		result := StepResult{Name: step.Name, MaxScore: step.Points}
+		for _, need := range step.Needs {
+			i, ok := index[need]
+			if !ok {
+				return nil, fmt.Errorf("step %q needs %q, which isn't declared before it", step.Name, need)
+			}
+			if result.SkippedBecause == "" && !results[i].Passed() {
+				// Blame the step that actually failed rather than one that
+				// was itself skipped.
+				result.SkippedBecause = results[i].SkippedBecause
+				if result.SkippedBecause == "" {
+					result.SkippedBecause = need
+				}
+			}
+		}
+
+		if result.SkippedBecause != "" {
+			result.Score = step.Points * step.SkippedCredit
+		} else {
+			start := time.Now()
+			result.Err = step.Run(ctx)
+			result.Duration = time.Since(start)
+			if result.Err == nil {
+				result.Score = step.Points
+			}
+		}
+
+		index[step.Name] = len(results)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// StepsResult reports each step as a test of a check.
+func StepsResult(results []StepResult) CheckResult {
+	result := CheckResult{Tests: make([]GradescopeTest, 0, len(results))}
+	for _, step := range results {
+		result.Score += step.Score
+		result.Tests = append(result.Tests, step.test())
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package grader
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func passStep(ctx context.Context) error { return nil }
+
+func failStep(ctx context.Context) error { return errors.New("failed") }
+
+func TestStepsSkipOnlyWhatDependsOnAFailure(t *testing.T) {
+	ran := map[string]bool{}
+	track := func(name string, run func(context.Context) error) func(context.Context) error {
+		return func(ctx context.Context) error {
+			ran[name] = true
+			return run(ctx)
+		}
+	}
+	steps := []Step{
+		{Name: "a", Points: 1, Run: track("a", failStep)},
+		{Name: "b", Points: 1, Needs: []string{"a"}, Run: track("b", passStep)},
+		{Name: "c", Points: 1, Needs: []string{"b"}, Run: track("c", passStep)},
+		{Name: "d", Points: 1, Run: track("d", passStep)},
+		{Name: "e", Points: 1, Needs: []string{"d"}, Run: track("e", passStep)},
+	}
+
+	results, err := RunSteps(context.Background(), steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		ran            bool
+		skippedBecause string
+		score          float64
+	}{
+		"a": {ran: true, score: 0},
+		"b": {skippedBecause: "a"},
+		// c is blamed on the step that actually failed, not on b.
+		"c": {skippedBecause: "a"},
+		"d": {ran: true, score: 1},
+		"e": {ran: true, score: 1},
+	}
+	for _, result := range results {
+		w := want[result.Name]
+		if ran[result.Name] != w.ran || result.SkippedBecause != w.skippedBecause || result.Score != w.score {
+			t.Errorf("%s: ran %v, skipped because %q, scored %v; want ran %v, skipped because %q, scored %v",
+				result.Name, ran[result.Name], result.SkippedBecause, result.Score, w.ran, w.skippedBecause, w.score)
+		}
+	}
+	if score := StepsResult(results).Score; score != 2 {
+		t.Errorf("scored %v, want 2", score)
+	}
+}
+
+func TestSkippedStepsGetTheirSkippedCredit(t *testing.T) {
+	results, err := RunSteps(context.Background(), []Step{
+		{Name: "a", Points: 4, Run: failStep},
+		{Name: "b", Points: 4, Needs: []string{"a"}, SkippedCredit: 0.5, Run: passStep},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[1].Score != 2 || results[1].MaxScore != 4 {
+		t.Errorf("skipped step scored %v of %v, want 2 of 4", results[1].Score, results[1].MaxScore)
+	}
+	if test := results[1].test(); test.Output != `Skipped because "a" failed` {
+		t.Errorf("skipped step's test says %q", test.Output)
+	}
+}
+
+func TestStepsMustBeDeclaredInOrder(t *testing.T) {
+	_, err := RunSteps(context.Background(), []Step{
+		{Name: "b", Needs: []string{"a"}, Run: passStep},
+		{Name: "a", Run: passStep},
+	})
+	if err == nil {
+		t.Error("a step needing one declared after it was accepted")
+	}
+
+	_, err = RunSteps(context.Background(), []Step{
+		{Name: "a", Run: passStep},
+		{Name: "a", Run: passStep},
+	})
+	if err == nil {
+		t.Error("a step declared twice was accepted")
+	}
+}
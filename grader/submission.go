@@ -0,0 +1,75 @@
+package grader
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/mholt/archiver/v4"
+)
+
+const SUBMISSION_DIR = "/autograder/submission"
+
+// A Submission is the student's checkout on disk.
+type Submission struct {
+	Dir string
+}
+
+// ReadFile returns the trimmed contents of a file at the top level of the
+// submission, e.g. SUNET or FLAG.
+func (s Submission) ReadFile(name string) (string, error) {
+	contents, err := os.ReadFile(filepath.Join(s.Dir, name))
+	if err != nil {
+		log.Println(err)
+		return "", err
+	}
+
+	return strings.TrimSpace(string(contents)), nil
+}
+
+func makeSubmissionZip(ctx context.Context, submission Submission, appDir string) ([]byte, error) {
+	// Make directories that would otherwise be Git submodules, but not included by Gradescope submission
+	err := os.MkdirAll(filepath.Join(submission.Dir, "web/dist"), 0777)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// The trailing separator archives the directory's contents at the root of
+	// the zip rather than under the directory's own name.
+	paths := map[string]string{
+		filepath.Clean(submission.Dir) + string(filepath.Separator): "",
+	}
+
+	if appDir != "" {
+		paths[appDir] = "app"
+	} else if _, err := os.Stat(filepath.Join(submission.Dir, "app")); os.IsNotExist(err) {
+		_, err = git.PlainCloneContext(ctx, filepath.Join(submission.Dir, "app"), false, &git.CloneOptions{
+			URL: "https://github.com/infracourse/yoctogram-app.git",
+		})
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+
+	files, err := archiver.FilesFromDisk(nil, paths)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	format := archiver.Zip{}
+	err = format.Archive(ctx, buf, files)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,162 @@
+package grader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const LAMBDA_GATEWAY_URI = "https://5tvpsbxptgyc6m7ffmgmxvdw7m0pbmkb.lambda-url.us-east-1.on.aws/"
+
+type LambdaRequest struct {
+	File    []byte
+	Context SynthContext
+
+	// Stacks are the stacks, by name, whose resources the rules check. The
+	// synthesizer merges only these, or every stack if there are none.
+	Stacks []string
+}
+
+// A SynthContext is what the submission's CDK app is synthesized with in
+// place of looking anything up in AWS, so that synthesis needs no AWS access
+// and comes out the same from one term to the next.
+type SynthContext struct {
+	// Account and Region are the environment the app is synthesized for,
+	// as CDK_DEFAULT_ACCOUNT and CDK_DEFAULT_REGION.
+	Account string `json:"account"`
+	Region  string `json:"region"`
+
+	// Values are cdk.context.json entries, keyed as the CDK keys the lookups
+	// they stand in for, such as
+	// availability-zones:account=123456789123:region=us-west-2.
+	Values map[string]interface{} `json:"values"`
+}
+
+func LoadSynthContext(path string) (SynthContext, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		log.Println(err)
+		return SynthContext{}, err
+	}
+
+	var synthContext SynthContext
+	err = yaml.UnmarshalStrict(contents, &synthContext)
+	if err != nil {
+		log.Println(err)
+		return SynthContext{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if synthContext.Account == "" || synthContext.Region == "" {
+		return SynthContext{}, fmt.Errorf("%s: account and region are required", path)
+	}
+	return synthContext, nil
+}
+
+// A SynthError means the synthesizer ran but couldn't unpack or synthesize
+// the submission, which is usually a problem with the student's zip or a
+// bug in their CDK code.
+type SynthError struct {
+	StatusCode int
+	Output     string
+}
+
+func (e *SynthError) Error() string {
+	return fmt.Sprintf("synthesizer lambda returned HTTP status code %d:\n%s", e.StatusCode, e.Output)
+}
+
+func getCfnResources(ctx context.Context, lambdaGatewayURI string, submissionZip []byte, synthContext SynthContext, stacks []string) (map[string]interface{}, error) {
+	request := LambdaRequest{File: submissionZip, Context: synthContext, Stacks: stacks}
+
+	buf := &bytes.Buffer{}
+	err := json.NewEncoder(buf).Encode(request)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", lambdaGatewayURI, buf)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Println("HTTP status code", resp.StatusCode)
+		output, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity {
+			return nil, &SynthError{StatusCode: resp.StatusCode, Output: string(output)}
+		}
+		return nil, fmt.Errorf("synthesizer lambda returned HTTP status code %d: %s", resp.StatusCode, output)
+	}
+
+	var resources map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&resources)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// loadTemplate reads an already synthesized CloudFormation template, either
+// a single stack's template or the synthesizer's merged output.
+func loadTemplate(path string) (map[string]interface{}, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	var resources map[string]interface{}
+	err = json.Unmarshal(contents, &resources)
+	if err != nil {
+		log.Println(err)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, ok := resources["Resources"]; !ok {
+		return nil, fmt.Errorf("%s: not a CloudFormation template, no Resources found", path)
+	}
+
+	return resources, nil
+}
+
+// missingStacks lists the required stacks that resources, as synthesized,
+// has none of. A stack counts under its own name whatever stage or parent
+// stack it's in. A template without the synthesizer's list of its stacks,
+// such as one loaded from disk, is taken to have them all.
+func missingStacks(resources map[string]interface{}, required []string) []string {
+	stacks, ok := resources["Stacks"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var missing []string
+	for _, name := range required {
+		found := false
+		for path := range stacks {
+			if path == name || strings.HasSuffix(path, "/"+name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
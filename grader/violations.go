@@ -0,0 +1,165 @@
+package grader
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A Violation is a single failed rule reported in data.rules.main. Rules may
+// emit either a bare message string or an object with these fields.
+type Violation struct {
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+
+	// Points overrides the manifest's violation_cost for this rule.
+	Points *float64 `json:"points,omitempty"`
+
+	Category string `json:"category,omitempty"`
+	Hint     string `json:"hint,omitempty"`
+
+	// Resource is the logical ID of the offending CloudFormation resource.
+	Resource string `json:"resource,omitempty"`
+}
+
+func parseViolations(value interface{}) ([]Violation, error) {
+	main, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("data.rules.main is a %T, not an object", value)
+	}
+
+	raw, ok := main["violations"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("data.rules.main.violations is a %T, not a set", main["violations"])
+	}
+
+	violations := make([]Violation, 0, len(raw))
+	for _, r := range raw {
+		switch r := r.(type) {
+		case string:
+			violations = append(violations, Violation{Message: r})
+		case map[string]interface{}:
+			// Round-trip through JSON rather than picking fields out by hand,
+			// since OPA hands numbers back as json.Number.
+			encoded, err := json.Marshal(r)
+			if err != nil {
+				return nil, err
+			}
+
+			var violation Violation
+			err = json.Unmarshal(encoded, &violation)
+			if err != nil {
+				return nil, fmt.Errorf("malformed violation %s: %w", encoded, err)
+			}
+			if violation.Message == "" {
+				return nil, fmt.Errorf("violation %s has no message", encoded)
+			}
+
+			violations = append(violations, violation)
+		default:
+			return nil, fmt.Errorf("violation %v is a %T, not a string or object", r, r)
+		}
+	}
+
+	return violations, nil
+}
+
+func (v Violation) test(points float64) GradescopeTest {
+	test := GradescopeTest{
+		Score:    0,
+		MaxScore: points,
+		Name:     v.Message,
+	}
+
+	var output []string
+	if v.ID != "" {
+		output = append(output, "Rule: "+v.ID)
+	}
+	if v.Resource != "" {
+		output = append(output, "Resource: "+v.Resource)
+	}
+	if v.Hint != "" {
+		output = append(output, "Hint: "+v.Hint)
+	}
+	test.Output = strings.Join(output, "\n")
+
+	if v.Category != "" {
+		test.Tags = []string{v.Category}
+	}
+
+	return test
+}
+
+// attributeViolations fills in each violation raised by a catalogued rule
+// from that rule's metadata.
+func attributeViolations(catalog []Rule, violations []Violation) []Violation {
+	rules := make(map[string]Rule, len(catalog))
+	for _, rule := range catalog {
+		rules[rule.ID] = rule
+	}
+
+	attributed := make([]Violation, 0, len(violations))
+	for _, violation := range violations {
+		if rule, ok := rules[violation.ID]; ok {
+			violation = violation.withRule(rule)
+		}
+		attributed = append(attributed, violation)
+	}
+	return attributed
+}
+
+// passingRules returns the catalogued rules that raised no violations and
+// had something in resources to check.
+func passingRules(catalog []Rule, violations []Violation, resources map[string]interface{}) []Rule {
+	failed := make(map[string]bool, len(violations))
+	for _, violation := range violations {
+		failed[violation.ID] = true
+	}
+
+	present := map[string]bool{}
+	templateResources, _ := resources["Resources"].(map[string]interface{})
+	for _, resource := range templateResources {
+		resource, _ := resource.(map[string]interface{})
+		if resourceType, ok := resource["Type"].(string); ok {
+			present[resourceType] = true
+		}
+	}
+
+	passing := make([]Rule, 0, len(catalog))
+	for _, rule := range catalog {
+		if failed[rule.ID] || !rule.applies(present) {
+			continue
+		}
+		passing = append(passing, rule)
+	}
+	return passing
+}
+
+// applies reports whether the rule had anything to check, given the
+// resource types present in the template.
+func (r Rule) applies(present map[string]bool) bool {
+	if len(r.ResourceTypes) == 0 {
+		return true
+	}
+	for _, resourceType := range r.ResourceTypes {
+		if present[resourceType] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) test(points float64) GradescopeTest {
+	test := GradescopeTest{
+		Score:    points,
+		MaxScore: points,
+		Name:     r.Title,
+		Output:   "Pass",
+	}
+
+	if r.Category != "" {
+		test.Tags = []string{r.Category}
+	}
+
+	return test
+}
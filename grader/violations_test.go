@@ -0,0 +1,105 @@
+package grader
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func loadA2Rules(t *testing.T) (RuleBundle, []Rule) {
+	t.Helper()
+	rules, err := loadRuleBundle(os.DirFS("../rules/a2-rules"), ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := ruleCatalog(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules, catalog
+}
+
+func TestRuntimePlatformIsOneDeduction(t *testing.T) {
+	rules, catalog := loadA2Rules(t)
+	for _, test := range []struct {
+		platform interface{}
+		want     int
+	}{
+		{map[string]interface{}{"OperatingSystemFamily": "LINUX", "CpuArchitecture": "ARM64"}, 0},
+		{map[string]interface{}{"OperatingSystemFamily": "WINDOWS_SERVER_2022_CORE", "CpuArchitecture": "X86_64"}, 1},
+		{map[string]interface{}{"CpuArchitecture": "X86_64"}, 1},
+		{nil, 1},
+	} {
+		properties := map[string]interface{}{}
+		if test.platform != nil {
+			properties["RuntimePlatform"] = test.platform
+		}
+		template := map[string]interface{}{
+			"Resources": map[string]interface{}{
+				"TaskDef": map[string]interface{}{
+					"Type":       "AWS::ECS::TaskDefinition",
+					"Properties": properties,
+				},
+			},
+		}
+
+		violations, err := evalRules(context.Background(), rules, template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		violations = attributeViolations(catalog, violations)
+
+		count := 0
+		manifest := Manifest{ViolationCost: 1}
+		for _, violation := range violations {
+			if violation.ID != "fargate-runtime-platform" {
+				continue
+			}
+			count++
+			if manifest.violationPoints(violation) != 1 {
+				t.Errorf("runtime platform violation costs %v, want 1", manifest.violationPoints(violation))
+			}
+		}
+		if count != test.want {
+			t.Errorf("got %d runtime platform violations for %v, want %d", count, test.platform, test.want)
+		}
+	}
+}
+
+func TestPassingRulesNeedSomethingToCheck(t *testing.T) {
+	rules, catalog := loadA2Rules(t)
+	template := map[string]interface{}{
+		"Resources": map[string]interface{}{
+			"Vpc": map[string]interface{}{
+				"Type":       "AWS::EC2::VPC",
+				"Properties": map[string]interface{}{"CidrBlock": "10.0.0.0/16"},
+			},
+		},
+	}
+
+	violations, err := evalRules(context.Background(), rules, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passing := map[string]bool{}
+	for _, rule := range passingRules(catalog, violations, template) {
+		passing[rule.ID] = true
+	}
+
+	if !passing["vpc-cidr"] || !passing["vpc-count"] {
+		t.Errorf("VPC rules aren't listed as passing: %v", passing)
+	}
+	for _, id := range []string{"fargate-memory", "fargate-cpu", "fargate-port-mapping-port", "aurora-engine"} {
+		if passing[id] {
+			t.Errorf("%s is listed as passing, but the template has nothing for it to check", id)
+		}
+	}
+}
+
+func TestRulesWithoutResourceTypesAlwaysApply(t *testing.T) {
+	rule := Rule{ID: "free-form"}
+	passing := passingRules([]Rule{rule}, nil, map[string]interface{}{})
+	if len(passing) != 1 {
+		t.Errorf("got %v, want the rule listed as passing", passing)
+	}
+}
@@ -0,0 +1,152 @@
+package yoctogram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"infracourse.cloud/a2-grader/grader"
+)
+
+// CLEANUP_TIMEOUT bounds tearing down what the probe created. Cleanup gets
+// its own time so that it still happens after the probe runs out of time.
+const CLEANUP_TIMEOUT = 30 * time.Second
+
+// The endpoints used to delete what the probe creates, where the
+// deployment implements them. Ones that don't leave things marked with
+// GRADER_PREFIX instead.
+const (
+	DELETE_IMAGE_PATH   = "/api/v1/images/media/"
+	DELETE_ACCOUNT_PATH = "/api/v1/auth/me/"
+)
+
+// ErrDeleteUnsupported means the deployment has no way to delete something.
+var ErrDeleteUnsupported = errors.New("the deployment doesn't support deleting it")
+
+type ResourceKind string
+
+const (
+	ACCOUNT ResourceKind = "account"
+	IMAGE   ResourceKind = "image"
+)
+
+// A Resource is something the probe created in the deployment.
+type Resource struct {
+	Kind ResourceKind
+	ID   string
+
+	// owner is the account that created the resource, whose token deletes
+	// it once logged in.
+	owner *Account
+}
+
+func (r Resource) String() string {
+	return fmt.Sprintf("%s %s", r.Kind, r.ID)
+}
+
+// DeleteImage deletes a post's image as the account that posted it.
+func (c *Client) DeleteImage(ctx context.Context, token string, id string) error {
+	return c.delete(ctx, "Deleting the image", DELETE_IMAGE_PATH+id, token)
+}
+
+// DeleteAccount deletes the account with the given token.
+func (c *Client) DeleteAccount(ctx context.Context, token string) error {
+	return c.delete(ctx, "Deleting the account", DELETE_ACCOUNT_PATH, token)
+}
+
+func (c *Client) delete(ctx context.Context, op string, path string, token string) error {
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "DELETE", c.BaseURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Origin", c.BaseURL)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented:
+		return ErrDeleteUnsupported
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &APIError{Op: op, StatusCode: resp.StatusCode, Detail: strings.TrimSpace(string(body))}
+}
+
+// A CleanupResult is the outcome of deleting one resource. A resource that
+// couldn't be deleted is left in place, marked with GRADER_PREFIX.
+type CleanupResult struct {
+	Resource Resource
+	Deleted  bool
+	Err      error
+}
+
+type CleanupReport struct {
+	Results []CleanupResult
+}
+
+// Summary describes the cleanup in a sentence.
+func (r CleanupReport) Summary() string {
+	deleted := 0
+	for _, result := range r.Results {
+		if result.Deleted {
+			deleted++
+		}
+	}
+	switch {
+	case len(r.Results) == 0:
+		return "The runtime checks didn't create anything that needed cleaning up."
+	case deleted == len(r.Results):
+		return fmt.Sprintf("Deleted all %d accounts and images the runtime checks created.", deleted)
+	}
+	return fmt.Sprintf("Deleted %d of %d accounts and images the runtime checks created; the rest are left in your deployment with names starting %q.", deleted, len(r.Results), GRADER_PREFIX)
+}
+
+// test reports the cleanup to the student, worth no points.
+func (r CleanupReport) test() grader.GradescopeTest {
+	return grader.GradescopeTest{
+		Name:   "Clean up test accounts and posts",
+		Output: r.Summary(),
+	}
+}
+
+// cleanup deletes what the probe created, newest first so that images go
+// before the accounts that own them.
+func cleanup(ctx context.Context, client *Client, created []Resource) CleanupReport {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), CLEANUP_TIMEOUT)
+	defer cancel()
+
+	report := CleanupReport{Results: make([]CleanupResult, 0, len(created))}
+	for i := len(created) - 1; i >= 0; i-- {
+		resource := created[i]
+		result := CleanupResult{Resource: resource}
+		switch {
+		case resource.owner.Token == "":
+			result.Err = errors.New("never logged in to its account")
+		case resource.Kind == IMAGE:
+			result.Err = client.DeleteImage(ctx, resource.owner.Token, resource.ID)
+		case resource.Kind == ACCOUNT:
+			result.Err = client.DeleteAccount(ctx, resource.owner.Token)
+		}
+		result.Deleted = result.Err == nil
+
+		if result.Deleted {
+			log.Printf("yoctogram: cleanup: deleted %s", resource)
+		} else {
+			log.Printf("yoctogram: cleanup: left %s in place: %v", resource, result.Err)
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
@@ -0,0 +1,359 @@
+// Package yoctogram probes a student's Yoctogram deployment through its
+// API: registering and logging in to accounts, uploading images through
+// presigned S3 POSTs, and checking who can see them.
+package yoctogram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"infracourse.cloud/a2-grader/grader"
+)
+
+// URL is where a student's deployment is served.
+func URL(sunet string) string {
+	return fmt.Sprintf("https://yoctogram.%s.infracourse.cloud", sunet)
+}
+
+// A Client makes Yoctogram API requests against a single deployment.
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+
+	// Retries is how many times a request is retried after a transient
+	// failure: a network error, or a 429, 502, 503 or 504 response.
+	Retries    int
+	RetryDelay time.Duration
+
+	// PollInterval is how often to check on something the deployment does
+	// in the background, such as compressing an image.
+	PollInterval time.Duration
+
+	requests atomic.Int64
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		HTTP:         grader.HTTPClient,
+		Retries:      2,
+		RetryDelay:   2 * time.Second,
+		PollInterval: 2 * time.Second,
+	}
+}
+
+// Requests is the number of HTTP requests made so far, including retries.
+func (c *Client) Requests() int {
+	return int(c.requests.Load())
+}
+
+// An APIError is an unsuccessful or unusable response from the deployment,
+// as opposed to not getting a response at all.
+type APIError struct {
+	Op         string
+	StatusCode int
+	Detail     string
+}
+
+func (e *APIError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%s failed: status code %d", e.Op, e.StatusCode)
+	}
+	return fmt.Sprintf("%s failed: %s; status code %d", e.Op, e.Detail, e.StatusCode)
+}
+
+type Account struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+
+	// Token is the account's access token once logged in.
+	Token string `json:"-"`
+}
+
+// GRADER_PREFIX starts the name of everything the grader creates in a
+// deployment, so that anything left behind can be recognized. It's
+// alphanumeric like the rest of the names, which deployments may restrict.
+const GRADER_PREFIX = "grader"
+
+// NewAccount makes up credentials for a new account. They come from rng so
+// that a replayed probe makes up the same ones; the accounts are throwaway,
+// so they needn't be unguessable.
+func NewAccount(rng *rand.Rand) Account {
+	return Account{
+		Username: GRADER_PREFIX + randomString(rng, 12),
+		Password: randomString(rng, 12),
+		Email:    GRADER_PREFIX + randomString(rng, 12) + "@infracourse.cloud",
+	}
+}
+
+func (c *Client) Register(ctx context.Context, account Account) error {
+	var response struct {
+		Success bool `json:"success"`
+	}
+	err := c.doJSON(ctx, "Account registration", "POST", "/api/v1/auth/register/", "", account, &response)
+	if err != nil {
+		return err
+	}
+	if !response.Success {
+		return errors.New("Account registration failed: the response didn't report success")
+	}
+	return nil
+}
+
+// Login sets the account's token.
+func (c *Client) Login(ctx context.Context, account *Account) error {
+	var response struct {
+		AccessToken string `json:"access_token"`
+	}
+	err := c.doJSON(ctx, "Login", "POST", "/api/v1/auth/login/", "", account, &response)
+	if err != nil {
+		return err
+	}
+	if response.AccessToken == "" {
+		return errors.New("Login failed: the response has no access token")
+	}
+	account.Token = response.AccessToken
+	return nil
+}
+
+type Privacy string
+
+const (
+	PUBLIC  Privacy = "public"
+	PRIVATE Privacy = "private"
+)
+
+// An Upload is a presigned S3 POST for a new post's image.
+type Upload struct {
+	ID     string            `json:"id"`
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
+
+func (c *Client) GenerateUpload(ctx context.Context, token string, privacy Privacy) (Upload, error) {
+	var response struct {
+		Success bool `json:"success"`
+		Upload
+	}
+	err := c.doJSON(ctx, "Upload URL generation", "POST", fmt.Sprintf("/api/v1/images/upload/%s/generate", privacy), token, nil, &response)
+	if err != nil {
+		return Upload{}, err
+	}
+	if !response.Success || response.URL == "" || response.ID == "" {
+		return Upload{}, errors.New("Upload URL generation failed: the response is missing the upload URL or image ID")
+	}
+	return response.Upload, nil
+}
+
+// UploadImage posts the image to the upload's presigned URL.
+func (c *Client) UploadImage(ctx context.Context, upload Upload, name string, contentType string, image []byte) error {
+	buf := &bytes.Buffer{}
+	form := multipart.NewWriter(buf)
+	// S3 ignores any fields after the file, so it must come last.
+	for key, value := range upload.Fields {
+		err := form.WriteField(key, value)
+		if err != nil {
+			return err
+		}
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, name))
+	header.Set("Content-Type", contentType)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(image)
+	if err != nil {
+		return err
+	}
+	err = form.Close()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", upload.URL, bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Image upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &APIError{Op: "Image upload", StatusCode: resp.StatusCode, Detail: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// Media is where a post's image is served from.
+type Media struct {
+	URI string `json:"uri"`
+}
+
+// Media looks up a post's image as the account with the given token.
+func (c *Client) Media(ctx context.Context, token string, id string) (Media, error) {
+	var media Media
+	err := c.doJSON(ctx, "Getting the image link", "GET", "/api/v1/images/media/"+id, token, nil, &media)
+	if err != nil {
+		return Media{}, err
+	}
+	if media.URI == "" {
+		return Media{}, &APIError{Op: "Getting the image link", StatusCode: http.StatusOK, Detail: "the response has no uri"}
+	}
+	return media, nil
+}
+
+// MEDIA_LIMIT bounds the size of an image download.
+const MEDIA_LIMIT = 32 * 1024 * 1024
+
+// Download fetches an image from where the deployment serves it, without
+// the API's headers or token: a media link has to work on its own.
+func (c *Client) Download(ctx context.Context, uri string) ([]byte, error) {
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", uri, nil)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Downloading the image failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &APIError{Op: "Downloading the image", StatusCode: resp.StatusCode, Detail: strings.TrimSpace(string(body))}
+	}
+
+	contents, err := io.ReadAll(io.LimitReader(resp.Body, MEDIA_LIMIT+1))
+	if err != nil {
+		return nil, fmt.Errorf("Downloading the image failed: %w", err)
+	}
+	if len(contents) > MEDIA_LIMIT {
+		return nil, &APIError{Op: "Downloading the image", StatusCode: resp.StatusCode, Detail: fmt.Sprintf("the image is over %d bytes", MEDIA_LIMIT)}
+	}
+	return contents, nil
+}
+
+// doJSON makes an API request, decoding a successful response into
+// response and an unsuccessful one into an APIError.
+func (c *Client) doJSON(ctx context.Context, op string, method string, path string, token string, body interface{}, response interface{}) error {
+	var encoded []byte
+	if body != nil {
+		var err error
+		encoded, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(encoded))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Origin", c.BaseURL)
+		req.Header.Set("Referer", c.BaseURL+"/")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	contents, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", op, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{Op: op, StatusCode: resp.StatusCode}
+		var detail struct {
+			Detail json.RawMessage `json:"detail"`
+		}
+		if json.Unmarshal(contents, &detail) == nil && detail.Detail != nil {
+			// FastAPI reports validation errors as a list rather than a
+			// string, so fall back to the raw JSON.
+			if json.Unmarshal(detail.Detail, &apiErr.Detail) != nil {
+				apiErr.Detail = string(detail.Detail)
+			}
+		}
+		return apiErr
+	}
+
+	err = json.Unmarshal(contents, response)
+	if err != nil {
+		return &APIError{Op: op, StatusCode: resp.StatusCode, Detail: "error parsing JSON in the response"}
+	}
+	return nil
+}
+
+// do makes a request, retrying it after transient failures. newRequest is
+// called for every attempt so that each gets a fresh body.
+func (c *Client) do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		c.requests.Add(1)
+		resp, err := c.HTTP.Do(req)
+		retry := attempt < c.Retries && ctx.Err() == nil
+		if err == nil && !(retry && transientStatus(resp.StatusCode)) {
+			return resp, nil
+		}
+		if err == nil {
+			log.Printf("%s %s: status code %d, retrying", req.Method, req.URL.Redacted(), resp.StatusCode)
+			resp.Body.Close()
+		} else if retry {
+			log.Printf("%v, retrying", err)
+		} else {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.RetryDelay * time.Duration(attempt+1)):
+		}
+	}
+}
+
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func randomString(rng *rand.Rand, length int) string {
+	const characters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	s := make([]byte, length)
+	for i := range s {
+		s[i] = characters[rng.Intn(len(characters))]
+	}
+	return string(s)
+}
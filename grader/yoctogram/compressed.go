@@ -0,0 +1,181 @@
+package yoctogram
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"time"
+
+	"infracourse.cloud/a2-grader/grader"
+	"infracourse.cloud/a2-grader/grader/compression"
+)
+
+// COMPRESSION_POLLS is how many times the public image is downloaded,
+// Client.PollInterval apart, waiting for the deployment to compress it. It's
+// a count rather than a time so that a replay makes the same downloads.
+const COMPRESSION_POLLS = 30
+
+// CACHE_BUSTING_PARAM is the query parameter that numbers each download of
+// the public image.
+const CACHE_BUSTING_PARAM = "poll"
+
+const (
+	RETRIEVE_PUBLIC       = "Retrieve image for public post from first account"
+	COMPRESSED_RATIO      = "Check retrieved public image is smaller than the upload"
+	COMPRESSED_FORMAT     = "Check retrieved public image's format"
+	COMPRESSED_DIMENSIONS = "Check retrieved public image's dimensions"
+	COMPRESSED_QUALITY    = "Check retrieved public image still looks like the upload"
+)
+
+// compressionSteps declares A3's probe, whose deployment compresses images
+// after they're posted. The rubric's other criteria need the image to have
+// shrunk, so that serving the upload untouched earns none of them.
+func (s *scenario) compressionSteps(rubric compression.Rubric) []grader.Step {
+	criterion := func(check func(compression.Measurement) error) func(context.Context) error {
+		return func(ctx context.Context) error { return check(s.measurement) }
+	}
+
+	return s.counted([]grader.Step{
+		{Name: CREATE_FIRST, Points: 4, Run: func(ctx context.Context) error { return s.register(ctx, &s.first) }},
+		{Name: LOGIN_FIRST, Points: 4, Needs: []string{CREATE_FIRST}, Run: func(ctx context.Context) error { return s.client.Login(ctx, &s.first) }},
+		{Name: POST_PUBLIC, Points: 8, Needs: []string{LOGIN_FIRST}, Run: func(ctx context.Context) error {
+			var err error
+			s.publicImage, err = randomPhoto(s.rng)
+			if err != nil {
+				return err
+			}
+			return s.upload(ctx, PUBLIC, &s.public, s.publicImage, ".jpg", "image/jpeg")
+		}},
+		{Name: FIRST_PUBLIC, Points: 8, Needs: []string{POST_PUBLIC}, Run: func(ctx context.Context) error {
+			media, err := s.client.Media(ctx, s.first.Token, s.public.ID)
+			s.publicURI = media.URI
+			return err
+		}},
+		{Name: RETRIEVE_PUBLIC, Points: 8, Needs: []string{FIRST_PUBLIC}, Run: s.retrieve},
+		{Name: COMPRESSED_RATIO, Points: 12, Needs: []string{RETRIEVE_PUBLIC}, Run: criterion(rubric.CheckRatio)},
+		{Name: COMPRESSED_FORMAT, Points: 4, Needs: []string{COMPRESSED_RATIO}, Run: criterion(rubric.CheckFormat)},
+		{Name: COMPRESSED_DIMENSIONS, Points: 4, Needs: []string{COMPRESSED_RATIO}, Run: criterion(rubric.CheckDimensions)},
+		{Name: COMPRESSED_QUALITY, Points: 8, Needs: []string{COMPRESSED_RATIO}, Run: criterion(rubric.CheckQuality)},
+	})
+}
+
+// retrieve downloads the public image once the deployment has compressed
+// it, which it does in the background, and measures it against the upload.
+// An image still unchanged after COMPRESSION_POLLS is measured as it is.
+//
+// CloudFront caches the first response it gets for a URL, so the deployment
+// is given PollInterval to compress the image before the first download,
+// and each download asks for the image under a query string of its own, so
+// that one that came too early doesn't leave the upload cached for the rest.
+func (s *scenario) retrieve(ctx context.Context) error {
+	for polls := 1; ; polls++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.client.PollInterval):
+		}
+
+		downloaded, err := s.client.Download(ctx, cacheBusted(s.publicURI, polls))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(downloaded, s.publicImage) || polls >= COMPRESSION_POLLS {
+			s.measurement, err = compression.Measure(s.publicImage, downloaded)
+			return err
+		}
+	}
+}
+
+// cacheBusted is uri with a poll query parameter, for which CloudFront has
+// no response cached yet when its cache policy keys on query strings. A uri
+// that doesn't parse is left alone, for Download to fail on.
+func cacheBusted(uri string, poll int) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := parsed.Query()
+	query.Set(CACHE_BUSTING_PARAM, strconv.Itoa(poll))
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// CompressionProbe exercises A3's deployment: it creates an account, posts
+// a photo-like JPEG from it, and judges the compressed image the deployment
+// then serves by rubric. Afterwards, it deletes what it created as far as
+// the deployment allows.
+//
+// What the probe makes up comes from seed, as for Probe.
+func CompressionProbe(ctx context.Context, client *Client, rubric compression.Rubric, seed int64) (Report, error) {
+	rng := rand.New(rand.NewSource(seed))
+	s := &scenario{client: client, rng: rng, first: NewAccount(rng), requests: map[string]int{}}
+	return s.run(ctx, s.compressionSteps(rubric))
+}
+
+// CompressionCheck probes the submitter's A3 deployment, judging its
+// compression by the rubric at options.CompressionRubricPath. It finds the
+// deployment and records or replays its cassette as RuntimeCheck does.
+func CompressionCheck(name string, options grader.Options) grader.Check {
+	maxScore := maxPoints((&scenario{}).compressionSteps(compression.Rubric{}))
+	return probeCheck(name, maxScore, options, func(ctx context.Context, client *Client, seed int64) (Report, error) {
+		rubric, err := compression.LoadRubric(options.CompressionRubricPath)
+		if err != nil {
+			return Report{}, err
+		}
+		return CompressionProbe(ctx, client, rubric, seed)
+	})
+}
+
+// randomPhoto is a 500x500 JPEG of random polygons on white, detailed
+// enough that compressing it further visibly costs something.
+func randomPhoto(rng *rand.Rand) ([]byte, error) {
+	const size = 500
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+
+	for polygon := 0; polygon < 10; polygon++ {
+		points := make([]image.Point, 3+rng.Intn(8))
+		for i := range points {
+			points[i] = image.Pt(rng.Intn(size+1), rng.Intn(size+1))
+		}
+		fill := color.RGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(256)), A: 255}
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				if inside(points, x, y) {
+					img.SetRGBA(x, y, fill)
+				}
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := jpeg.Encode(buf, img, nil)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// inside is whether the pixel at x, y is inside the polygon, by the even-odd
+// rule.
+func inside(polygon []image.Point, x int, y int) bool {
+	px, py := float64(x)+0.5, float64(y)+0.5
+	in := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (float64(a.Y) > py) != (float64(b.Y) > py) {
+			crossing := float64(a.X) + (py-float64(a.Y))*float64(b.X-a.X)/float64(b.Y-a.Y)
+			if px < crossing {
+				in = !in
+			}
+		}
+	}
+	return in
+}
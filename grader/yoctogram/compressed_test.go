@@ -0,0 +1,127 @@
+package yoctogram
+
+import (
+	"bytes"
+	"context"
+	"image/jpeg"
+	"net/url"
+	"testing"
+
+	"infracourse.cloud/a2-grader/grader/compression"
+)
+
+// compressor stands in for a deployment that compresses images in the
+// background, served through a CDN that caches the first response for
+// each URL.
+type compressor struct {
+	t *testing.T
+
+	// after is how many downloads the image is served untouched for
+	// before it's compressed, or -1 if it never is.
+	after int
+
+	downloads int
+	queries   []url.Values
+	cache     map[string][]byte
+}
+
+func newCompressor(t *testing.T, after int) *compressor {
+	return &compressor{t: t, after: after, cache: map[string][]byte{}}
+}
+
+func (c *compressor) serve(id string, image fakeImage, query string) []byte {
+	values, _ := url.ParseQuery(query)
+	c.queries = append(c.queries, values)
+	c.downloads++
+	if cached, ok := c.cache[id+"?"+query]; ok {
+		return cached
+	}
+
+	served := image.contents
+	if c.after >= 0 && c.downloads > c.after {
+		decoded, err := jpeg.Decode(bytes.NewReader(image.contents))
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		err = jpeg.Encode(buf, decoded, &jpeg.Options{Quality: 30})
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		served = buf.Bytes()
+	}
+	c.cache[id+"?"+query] = served
+	return served
+}
+
+func compressionProbe(t *testing.T, c *compressor) map[string]StepResult {
+	t.Helper()
+	fake := newFakeYoctogram(t)
+	fake.serve = c.serve
+	report, err := CompressionProbe(context.Background(), fake.client(), compression.DefaultRubric(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := map[string]StepResult{}
+	for _, step := range report.Steps {
+		steps[step.Name] = step
+	}
+	return steps
+}
+
+func TestCompressionProbePassesCompressedImage(t *testing.T) {
+	c := newCompressor(t, 0)
+	steps := compressionProbe(t, c)
+
+	for name, step := range steps {
+		if !step.Passed() {
+			t.Errorf("%s: %s", name, step.Outcome())
+		}
+	}
+	if c.downloads != 1 || c.queries[0].Get(CACHE_BUSTING_PARAM) != "1" {
+		t.Errorf("downloaded the image %d times, first with %v", c.downloads, c.queries[0])
+	}
+}
+
+func TestCompressionProbeSkipsCachedOriginal(t *testing.T) {
+	// The first download comes before the image is compressed, and the CDN
+	// keeps serving the original for that URL.
+	c := newCompressor(t, 1)
+	steps := compressionProbe(t, c)
+
+	for name, step := range steps {
+		if !step.Passed() {
+			t.Errorf("%s: %s", name, step.Outcome())
+		}
+	}
+	if c.downloads != 2 || c.queries[0].Get(CACHE_BUSTING_PARAM) == c.queries[1].Get(CACHE_BUSTING_PARAM) {
+		t.Errorf("downloaded the image %d times, with %v", c.downloads, c.queries)
+	}
+}
+
+func TestCompressionProbeFailsUncompressedImage(t *testing.T) {
+	c := newCompressor(t, -1)
+	steps := compressionProbe(t, c)
+
+	if !steps[RETRIEVE_PUBLIC].Passed() {
+		t.Errorf("%s: %s", RETRIEVE_PUBLIC, steps[RETRIEVE_PUBLIC].Outcome())
+	}
+	if c.downloads != COMPRESSION_POLLS {
+		t.Errorf("downloaded the image %d times, want %d", c.downloads, COMPRESSION_POLLS)
+	}
+	if steps[COMPRESSED_RATIO].Passed() {
+		t.Errorf("%s passed, though the upload was served untouched", COMPRESSED_RATIO)
+	}
+	for _, name := range []string{COMPRESSED_FORMAT, COMPRESSED_DIMENSIONS, COMPRESSED_QUALITY} {
+		if steps[name].SkippedBecause != COMPRESSED_RATIO {
+			t.Errorf("%s: %s, want it skipped because %q failed", name, steps[name].Outcome(), COMPRESSED_RATIO)
+		}
+	}
+}
+
+func TestCacheBustedKeepsTheQuery(t *testing.T) {
+	got := cacheBusted("https://cdn.example.com/1.jpg?Expires=1", 3)
+	if got != "https://cdn.example.com/1.jpg?Expires=1&poll=3" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,169 @@
+package yoctogram
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeImage is an image posted to a fakeYoctogram.
+type fakeImage struct {
+	owner    string
+	privacy  Privacy
+	contents []byte
+}
+
+// A fakeYoctogram serves the parts of the Yoctogram API the probes use,
+// along with the S3 bucket images are posted to and the CDN they're served
+// from. Its fields break it in the ways a student's deployment might be.
+type fakeYoctogram struct {
+	*httptest.Server
+
+	// leakPrivate lets any account look up a private image.
+	leakPrivate bool
+
+	// unsignedPrivate serves private images without checking their
+	// signature.
+	unsignedPrivate bool
+
+	// failFirstLogin fails logging in to the first account registered.
+	failFirstLogin bool
+
+	// unavailable is how many requests are answered 503 before any are
+	// served.
+	unavailable int
+
+	// serve, if set, serves an image's CDN download in place of its
+	// contents, such as to stand in for a deployment that compresses it.
+	serve func(id string, image fakeImage, query string) []byte
+
+	mu       sync.Mutex
+	accounts map[string]string
+	first    string
+	images   map[string]*fakeImage
+	nextID   int
+	deleted  []string
+}
+
+func newFakeYoctogram(t *testing.T) *fakeYoctogram {
+	fake := &fakeYoctogram{accounts: map[string]string{}, images: map[string]*fakeImage{}}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.handle))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// client is a client of the fake that doesn't wait between retries or polls.
+func (f *fakeYoctogram) client() *Client {
+	client := NewClient(f.URL)
+	client.HTTP = f.Client()
+	client.RetryDelay = 0
+	client.PollInterval = time.Millisecond
+	return client
+}
+
+func (f *fakeYoctogram) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.unavailable > 0 {
+		f.unavailable--
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	user := f.accounts[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer token-")]
+	path := r.URL.Path
+	switch {
+	case r.Method == "POST" && path == "/api/v1/auth/register/":
+		var account Account
+		_ = json.NewDecoder(r.Body).Decode(&account)
+		f.accounts[account.Username] = account.Username
+		if f.first == "" {
+			f.first = account.Username
+		}
+		writeJSON(w, map[string]interface{}{"success": true})
+	case r.Method == "POST" && path == "/api/v1/auth/login/":
+		var account Account
+		_ = json.NewDecoder(r.Body).Decode(&account)
+		if _, ok := f.accounts[account.Username]; !ok || (f.failFirstLogin && account.Username == f.first) {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]interface{}{"detail": "Incorrect username or password"})
+			return
+		}
+		writeJSON(w, map[string]interface{}{"access_token": "token-" + account.Username})
+	case r.Method == "POST" && strings.HasPrefix(path, "/api/v1/images/upload/"):
+		if user == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		f.nextID++
+		id := fmt.Sprint(f.nextID)
+		privacy := Privacy(strings.TrimSuffix(strings.TrimPrefix(path, "/api/v1/images/upload/"), "/generate"))
+		f.images[id] = &fakeImage{owner: user, privacy: privacy}
+		writeJSON(w, map[string]interface{}{"success": true, "id": id, "url": f.URL + "/s3/" + id, "fields": map[string]string{"key": id}})
+	case r.Method == "POST" && strings.HasPrefix(path, "/s3/"):
+		image := f.images[strings.TrimPrefix(path, "/s3/")]
+		file, _, err := r.FormFile("file")
+		if image == nil || err != nil {
+			http.Error(w, "bad upload", http.StatusBadRequest)
+			return
+		}
+		image.contents, _ = io.ReadAll(file)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "GET" && strings.HasPrefix(path, "/api/v1/images/media/"):
+		id := strings.TrimPrefix(path, "/api/v1/images/media/")
+		image := f.images[id]
+		if image == nil || user == "" || (image.privacy == PRIVATE && image.owner != user && !f.leakPrivate) {
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(w, map[string]interface{}{"detail": "Not found"})
+			return
+		}
+		uri := f.URL + "/cdn/" + id
+		if image.privacy == PRIVATE {
+			uri += "?Expires=1&Signature=signature" + id + "&Key-Pair-Id=K1"
+		}
+		writeJSON(w, map[string]interface{}{"uri": uri})
+	case r.Method == "GET" && strings.HasPrefix(path, "/cdn/"):
+		id := strings.TrimPrefix(path, "/cdn/")
+		image := f.images[id]
+		if image == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if image.privacy == PRIVATE && !f.unsignedPrivate && r.URL.Query().Get("Signature") != "signature"+id {
+			http.Error(w, "Missing Key-Pair-Id query parameter or cookie value", http.StatusForbidden)
+			return
+		}
+		if f.serve != nil {
+			_, _ = w.Write(f.serve(id, *image, r.URL.RawQuery))
+			return
+		}
+		_, _ = w.Write(image.contents)
+	case r.Method == "DELETE" && strings.HasPrefix(path, DELETE_IMAGE_PATH):
+		id := strings.TrimPrefix(path, DELETE_IMAGE_PATH)
+		if image := f.images[id]; image == nil || image.owner != user {
+			http.NotFound(w, r)
+			return
+		}
+		delete(f.images, id)
+		f.deleted = append(f.deleted, "image "+id)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "DELETE" && path == DELETE_ACCOUNT_PATH && user != "":
+		delete(f.accounts, user)
+		f.deleted = append(f.deleted, "account "+user)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,142 @@
+package yoctogram
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+)
+
+// IMAGE_TOLERANCE is how far, out of 255, a downloaded image's colors may
+// drift from the uploaded image's and still count as the same image. It
+// allows for a deployment that re-encodes images, such as to compress them.
+const IMAGE_TOLERANCE = 12
+
+// IMAGE_GRID is how many cells across and down images are averaged over to
+// compare them, so that they can be compared at different sizes.
+const IMAGE_GRID = 8
+
+// SIGNATURE_PARAMS are the query parameters carrying a URL's signature:
+// CloudFront's, then S3's.
+var SIGNATURE_PARAMS = []string{"Signature", "X-Amz-Signature"}
+
+// sameImage checks that a downloaded image is the one uploaded, either
+// byte for byte or, failing that, by looking the same.
+func sameImage(downloaded []byte, uploaded []byte) error {
+	if sha256.Sum256(downloaded) == sha256.Sum256(uploaded) {
+		return nil
+	}
+
+	got, format, err := image.Decode(bytes.NewReader(downloaded))
+	if err != nil {
+		return fmt.Errorf("The downloaded file isn't an image that could be read (%v)", err)
+	}
+	want, _, err := image.Decode(bytes.NewReader(uploaded))
+	if err != nil {
+		return err
+	}
+
+	if !sameAspect(got.Bounds(), want.Bounds()) {
+		return fmt.Errorf("The downloaded image is %dx%d, but the uploaded one was %dx%d", got.Bounds().Dx(), got.Bounds().Dy(), want.Bounds().Dx(), want.Bounds().Dy())
+	}
+	gotGrid, wantGrid := averageGrid(got), averageGrid(want)
+	for i := range gotGrid {
+		for c := 0; c < 3; c++ {
+			if diff := gotGrid[i][c] - wantGrid[i][c]; diff > IMAGE_TOLERANCE || diff < -IMAGE_TOLERANCE {
+				return fmt.Errorf("The downloaded %s image doesn't match the uploaded one", format)
+			}
+		}
+	}
+	return nil
+}
+
+// sameAspect allows for an image being scaled down, as long as it's by the
+// same factor in both directions give or take a pixel.
+func sameAspect(a image.Rectangle, b image.Rectangle) bool {
+	if a.Dx() == 0 || a.Dy() == 0 || b.Dx() == 0 || b.Dy() == 0 {
+		return false
+	}
+	scaled := float64(a.Dx()) * float64(b.Dy()) / float64(b.Dx())
+	return scaled-float64(a.Dy()) <= 1 && float64(a.Dy())-scaled <= 1
+}
+
+// averageGrid is the average 8-bit RGB color of each cell of an
+// IMAGE_GRID by IMAGE_GRID grid over the image.
+func averageGrid(img image.Image) [][3]int {
+	bounds := img.Bounds()
+	var sums [IMAGE_GRID * IMAGE_GRID][4]int64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := (y - bounds.Min.Y) * IMAGE_GRID / bounds.Dy()
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			col := (x - bounds.Min.X) * IMAGE_GRID / bounds.Dx()
+			r, g, b, _ := img.At(x, y).RGBA()
+			cell := &sums[row*IMAGE_GRID+col]
+			cell[0] += int64(r >> 8)
+			cell[1] += int64(g >> 8)
+			cell[2] += int64(b >> 8)
+			cell[3]++
+		}
+	}
+
+	grid := make([][3]int, 0, len(sums))
+	for _, cell := range sums {
+		if cell[3] == 0 {
+			// The image is smaller than the grid, so this cell is empty.
+			grid = append(grid, [3]int{})
+			continue
+		}
+		grid = append(grid, [3]int{int(cell[0] / cell[3]), int(cell[1] / cell[3]), int(cell[2] / cell[3])})
+	}
+	return grid
+}
+
+// A variant is a media link altered so that it shouldn't be served.
+type variant struct {
+	Description string
+	URI         string
+}
+
+// unsignedVariants are a signed media link with its signature removed and
+// with its signature tampered with. It fails if the link isn't signed.
+func unsignedVariants(uri string) ([]variant, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	query := parsed.Query()
+
+	param := ""
+	for _, p := range SIGNATURE_PARAMS {
+		if query.Get(p) != "" {
+			param = p
+			break
+		}
+	}
+	if param == "" {
+		return nil, errors.New("The private image's link isn't a signed URL")
+	}
+
+	unsigned := *parsed
+	unsigned.RawQuery = ""
+
+	signature := []byte(query.Get(param))
+	i := len(signature) / 2
+	if signature[i] == 'A' {
+		signature[i] = 'B'
+	} else {
+		signature[i] = 'A'
+	}
+	query.Set(param, string(signature))
+	tampered := *parsed
+	tampered.RawQuery = query.Encode()
+
+	return []variant{
+		{Description: "without its signature", URI: unsigned.String()},
+		{Description: "with a tampered-with signature", URI: tampered.String()},
+	}, nil
+}
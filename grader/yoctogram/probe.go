@@ -0,0 +1,346 @@
+package yoctogram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math/rand"
+	"time"
+
+	"infracourse.cloud/a2-grader/grader"
+	"infracourse.cloud/a2-grader/grader/compression"
+)
+
+// A StepResult is the outcome of one step of the probe.
+type StepResult struct {
+	grader.StepResult
+
+	// Requests counts the HTTP requests the step made, including retries.
+	Requests int
+}
+
+// A Report holds the results of every step of the probe, in order, and of
+// cleaning up after it.
+type Report struct {
+	Steps   []StepResult
+	Cleanup CleanupReport
+}
+
+// Result reports each step as a test of a check, followed by the cleanup.
+func (r Report) Result() grader.CheckResult {
+	results := make([]grader.StepResult, 0, len(r.Steps))
+	for _, step := range r.Steps {
+		results = append(results, step.StepResult)
+	}
+	result := grader.StepsResult(results)
+	result.Tests = append(result.Tests, r.Cleanup.test())
+	return result
+}
+
+// scenario is the state shared between the probe's steps.
+type scenario struct {
+	client *Client
+
+	// rng makes up the probe's accounts and images.
+	rng *rand.Rand
+
+	first   Account
+	second  Account
+	public  Upload
+	private Upload
+
+	// publicImage and privateImage are the images uploaded to each post,
+	// and privateURI is the link the first account was given to the
+	// private one.
+	publicImage  []byte
+	privateImage []byte
+	privateURI   string
+
+	// publicURI is the link to the public image, and measurement how it
+	// compares with the upload, for A3's compression probe.
+	publicURI   string
+	measurement compression.Measurement
+
+	// requests counts each step's requests by name.
+	requests map[string]int
+
+	// created is everything the probe has created, in order.
+	created []Resource
+}
+
+const (
+	CREATE_FIRST   = "Create first account"
+	LOGIN_FIRST    = "Login to first account"
+	CREATE_SECOND  = "Create second account"
+	LOGIN_SECOND   = "Login to second account"
+	POST_PUBLIC    = "Create public post from first account"
+	POST_PRIVATE   = "Create private post from first account"
+	FIRST_PUBLIC   = "Check for public post from first account"
+	FIRST_PRIVATE  = "Check for private post from first account"
+	SECOND_PUBLIC  = "Check for public post from second account"
+	SECOND_PRIVATE = "Check private post isn't accessible from second account"
+	PRIVATE_SIGNED = "Check private image is only served from a signed URL"
+)
+
+// steps declares the probe as a graph, so that e.g. a failure to create the
+// second account doesn't hide whether posting from the first works. Each
+// step is worth points, except for checking the private image's link is
+// signed, which is worth signedPoints.
+func (s *scenario) steps(points float64, signedPoints float64) []grader.Step {
+	steps := []grader.Step{
+		{Name: CREATE_FIRST, Run: func(ctx context.Context) error { return s.register(ctx, &s.first) }},
+		{Name: LOGIN_FIRST, Needs: []string{CREATE_FIRST}, Run: func(ctx context.Context) error { return s.client.Login(ctx, &s.first) }},
+		{Name: CREATE_SECOND, Run: func(ctx context.Context) error { return s.register(ctx, &s.second) }},
+		{Name: LOGIN_SECOND, Needs: []string{CREATE_SECOND}, Run: func(ctx context.Context) error { return s.client.Login(ctx, &s.second) }},
+		{Name: POST_PUBLIC, Needs: []string{LOGIN_FIRST}, Run: func(ctx context.Context) error { return s.post(ctx, PUBLIC, &s.public, &s.publicImage) }},
+		{Name: POST_PRIVATE, Needs: []string{LOGIN_FIRST}, Run: func(ctx context.Context) error { return s.post(ctx, PRIVATE, &s.private, &s.privateImage) }},
+		{Name: FIRST_PUBLIC, Needs: []string{POST_PUBLIC}, Run: func(ctx context.Context) error {
+			_, err := s.visible(ctx, s.first, s.public, s.publicImage)
+			return err
+		}},
+		{Name: FIRST_PRIVATE, Needs: []string{POST_PRIVATE}, Run: func(ctx context.Context) error {
+			var err error
+			s.privateURI, err = s.visible(ctx, s.first, s.private, s.privateImage)
+			return err
+		}},
+		{Name: SECOND_PUBLIC, Needs: []string{POST_PUBLIC, LOGIN_SECOND}, Run: func(ctx context.Context) error {
+			_, err := s.visible(ctx, s.second, s.public, s.publicImage)
+			return err
+		}},
+		{Name: SECOND_PRIVATE, Needs: []string{POST_PRIVATE, LOGIN_SECOND}, Run: func(ctx context.Context) error { return s.hidden(ctx, s.second, s.private) }},
+		{Name: PRIVATE_SIGNED, Needs: []string{FIRST_PRIVATE}, Run: func(ctx context.Context) error { return s.signed(ctx, s.privateURI) }},
+	}
+	for i := range steps {
+		steps[i].Points = points
+		if steps[i].Name == PRIVATE_SIGNED {
+			steps[i].Points = signedPoints
+		}
+	}
+	return s.counted(steps)
+}
+
+// counted wraps each step to count the requests it makes.
+func (s *scenario) counted(steps []grader.Step) []grader.Step {
+	for i := range steps {
+		name, run := steps[i].Name, steps[i].Run
+		steps[i].Run = func(ctx context.Context) error {
+			requests := s.client.Requests()
+			err := run(ctx)
+			s.requests[name] = s.client.Requests() - requests
+			return err
+		}
+	}
+	return steps
+}
+
+// Probe exercises the deployment the way a user would: it creates two
+// accounts, posts a public and a private image from the first, and checks
+// that the second can see only the public one, that each image downloads
+// intact, and that the private one can't be downloaded without a valid
+// signed link. Each step is worth points, and the signed link check
+// signedPoints; a step is skipped, scoring nothing, if a step it depends on
+// failed. Afterwards, it deletes what it
+// created as far as the deployment allows.
+//
+// What the probe makes up comes from seed, so probing a replay of a
+// recorded probe with the same seed makes the same requests.
+func Probe(ctx context.Context, client *Client, points float64, signedPoints float64, seed int64) (Report, error) {
+	rng := rand.New(rand.NewSource(seed))
+	s := &scenario{client: client, rng: rng, first: NewAccount(rng), second: NewAccount(rng), requests: map[string]int{}}
+	return s.run(ctx, s.steps(points, signedPoints))
+}
+
+// run runs a scenario's steps and then cleans up after them.
+func (s *scenario) run(ctx context.Context, steps []grader.Step) (Report, error) {
+	results, err := grader.RunSteps(ctx, steps)
+	if err != nil {
+		log.Println(err)
+		return Report{}, err
+	}
+
+	report := Report{Steps: make([]StepResult, 0, len(results))}
+	for _, result := range results {
+		step := StepResult{StepResult: result, Requests: s.requests[result.Name]}
+		log.Printf("yoctogram: %s: %s in %v with %d requests", step.Name, step.Outcome(), step.Duration.Round(time.Millisecond), step.Requests)
+		report.Steps = append(report.Steps, step)
+	}
+	report.Cleanup = cleanup(ctx, s.client, s.created)
+	return report, nil
+}
+
+func (s *scenario) register(ctx context.Context, account *Account) error {
+	err := s.client.Register(ctx, *account)
+	if err != nil {
+		return err
+	}
+	s.created = append(s.created, Resource{Kind: ACCOUNT, ID: account.Username, owner: account})
+	return nil
+}
+
+func (s *scenario) post(ctx context.Context, privacy Privacy, upload *Upload, image *[]byte) error {
+	var err error
+	*image, err = randomImage(s.rng)
+	if err != nil {
+		return err
+	}
+	return s.upload(ctx, privacy, upload, *image, ".png", "image/png")
+}
+
+// upload posts an image from the first account.
+func (s *scenario) upload(ctx context.Context, privacy Privacy, upload *Upload, image []byte, extension string, contentType string) error {
+	var err error
+	*upload, err = s.client.GenerateUpload(ctx, s.first.Token, privacy)
+	if err != nil {
+		return err
+	}
+	s.created = append(s.created, Resource{Kind: IMAGE, ID: upload.ID, owner: &s.first})
+	return s.client.UploadImage(ctx, *upload, GRADER_PREFIX+randomString(s.rng, 12)+extension, contentType, image)
+}
+
+// visible checks that the account can download the image posted, returning
+// the link it was given to it.
+func (s *scenario) visible(ctx context.Context, account Account, upload Upload, image []byte) (string, error) {
+	media, err := s.client.Media(ctx, account.Token, upload.ID)
+	if err != nil {
+		return "", err
+	}
+	downloaded, err := s.client.Download(ctx, media.URI)
+	if err != nil {
+		return "", err
+	}
+	return media.URI, sameImage(downloaded, image)
+}
+
+func (s *scenario) hidden(ctx context.Context, account Account, upload Upload) error {
+	media, err := s.client.Media(ctx, account.Token, upload.ID)
+	if err == nil {
+		return fmt.Errorf("Expected the private post to be inaccessible, but got its link %s", media.URI)
+	}
+
+	// Being refused counts, but not getting an answer at all doesn't.
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	return nil
+}
+
+// signed checks that the private image's link is signed, and that the
+// image isn't served without the signature or with a forged one.
+func (s *scenario) signed(ctx context.Context, uri string) error {
+	variants, err := unsignedVariants(uri)
+	if err != nil {
+		return err
+	}
+	for _, variant := range variants {
+		_, err := s.client.Download(ctx, variant.URI)
+		if err == nil {
+			return fmt.Errorf("Expected the private image to be inaccessible %s, but it was served", variant.Description)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			return err
+		}
+	}
+	return nil
+}
+
+// randomImage is a 1000x1000 PNG of a random solid color.
+func randomImage(rng *rand.Rand) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, 1000, 1000))
+	fill := color.RGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(256)), A: 255}
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+	}
+
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, img)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RuntimeCheck probes the submitter's deployment, worth pointsPerStep for
+// each step of the probe but the signed link check, which is worth
+// signedPoints. The deployment is at options.YoctogramURL if set,
+// and otherwise at the URL for the SUNet ID in the submission's SUNET file.
+//
+// The probe's HTTP exchanges are recorded to the check's cassette, which is
+// kept in results.json and in the cassette directory if options name one,
+// and with options.Replay the probe is replayed from that cassette instead
+// of reaching the deployment.
+func RuntimeCheck(name string, pointsPerStep float64, signedPoints float64, options grader.Options) grader.Check {
+	return probeCheck(name, maxPoints((&scenario{}).steps(pointsPerStep, signedPoints)), options, func(ctx context.Context, client *Client, seed int64) (Report, error) {
+		return Probe(ctx, client, pointsPerStep, signedPoints, seed)
+	})
+}
+
+func maxPoints(steps []grader.Step) float64 {
+	var points float64
+	for _, step := range steps {
+		points += step.Points
+	}
+	return points
+}
+
+// probeCheck is a check that probes the submitter's deployment, recording
+// or replaying its cassette as options say.
+func probeCheck(name string, maxScore float64, options grader.Options, probe func(ctx context.Context, client *Client, seed int64) (Report, error)) grader.Check {
+	return grader.Check{
+		Name:     name,
+		MaxScore: maxScore,
+		Run: func(ctx context.Context, submission grader.Submission) (grader.CheckResult, error) {
+			url := options.YoctogramURL
+			if url == "" {
+				sunet, err := submission.ReadFile("SUNET")
+				if err != nil {
+					log.Println(err)
+					return grader.CheckResult{}, err
+				}
+				url = URL(sunet)
+			}
+
+			client := NewClient(url)
+			path := options.CassettePath(name)
+			var cassette *grader.Cassette
+			if options.Replay {
+				var err error
+				cassette, err = options.LoadCassette(name)
+				if err != nil {
+					log.Println(err)
+					return grader.CheckResult{}, err
+				}
+				log.Printf("yoctogram: replaying the %s cassette recorded at %v", name, cassette.RecordedAt)
+				client.HTTP = cassette.Player()
+				client.RetryDelay = 0
+				client.PollInterval = 0
+			} else {
+				cassette = grader.NewCassette(rand.Int63())
+				client.HTTP = cassette.Recorder(client.HTTP)
+			}
+
+			report, err := probe(ctx, client, cassette.Seed)
+			if path != "" && !options.Replay {
+				// A failure to record shouldn't cost the student points.
+				saveErr := cassette.Save(path)
+				if saveErr != nil {
+					log.Println(saveErr)
+				}
+			}
+			if err != nil {
+				return grader.CheckResult{}, err
+			}
+			// A replay keeps the cassette too, so that its results can be
+			// replayed in turn.
+			result := report.Result()
+			result.Cassette = cassette
+			return result, nil
+		},
+	}
+}
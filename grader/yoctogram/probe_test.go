@@ -0,0 +1,120 @@
+package yoctogram
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"infracourse.cloud/a2-grader/grader"
+)
+
+func probeFake(t *testing.T, fake *fakeYoctogram) map[string]StepResult {
+	t.Helper()
+	report, err := Probe(context.Background(), fake.client(), 4, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := map[string]StepResult{}
+	for _, step := range report.Steps {
+		steps[step.Name] = step
+	}
+	return steps
+}
+
+func TestProbePassesCorrectDeployment(t *testing.T) {
+	fake := newFakeYoctogram(t)
+	report, err := Probe(context.Background(), fake.client(), 4, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range report.Steps {
+		if !step.Passed() {
+			t.Errorf("%s: %s", step.Name, step.Outcome())
+		}
+	}
+	if result := report.Result(); result.Score != 41 {
+		t.Errorf("scored %v, want 41", result.Score)
+	}
+	if len(fake.accounts) != 0 || len(fake.images) != 0 {
+		t.Errorf("cleanup left %d accounts and %d images", len(fake.accounts), len(fake.images))
+	}
+	if want := "Deleted all 4 accounts and images the runtime checks created."; report.Cleanup.Summary() != want {
+		t.Errorf("cleanup summary %q, want %q", report.Cleanup.Summary(), want)
+	}
+}
+
+func TestProbeCatchesLeakedPrivatePost(t *testing.T) {
+	fake := newFakeYoctogram(t)
+	fake.leakPrivate = true
+	steps := probeFake(t, fake)
+
+	if steps[SECOND_PRIVATE].Passed() {
+		t.Errorf("%s passed, though the second account could see the private post", SECOND_PRIVATE)
+	}
+	for _, name := range []string{SECOND_PUBLIC, FIRST_PRIVATE, PRIVATE_SIGNED} {
+		if !steps[name].Passed() {
+			t.Errorf("%s: %s", name, steps[name].Outcome())
+		}
+	}
+}
+
+func TestProbeCatchesUnsignedPrivateImage(t *testing.T) {
+	fake := newFakeYoctogram(t)
+	fake.unsignedPrivate = true
+	steps := probeFake(t, fake)
+
+	if steps[PRIVATE_SIGNED].Passed() {
+		t.Errorf("%s passed, though the private image is served without a signature", PRIVATE_SIGNED)
+	}
+	if steps[PRIVATE_SIGNED].Score != 0 || steps[PRIVATE_SIGNED].MaxScore != 1 {
+		t.Errorf("%s scored %v of %v, want 0 of 1", PRIVATE_SIGNED, steps[PRIVATE_SIGNED].Score, steps[PRIVATE_SIGNED].MaxScore)
+	}
+}
+
+func TestProbeSkipsStepsAfterFailedLogin(t *testing.T) {
+	fake := newFakeYoctogram(t)
+	fake.failFirstLogin = true
+	steps := probeFake(t, fake)
+
+	if steps[LOGIN_FIRST].Passed() {
+		t.Fatalf("%s passed", LOGIN_FIRST)
+	}
+	for _, name := range []string{POST_PUBLIC, POST_PRIVATE, FIRST_PUBLIC, SECOND_PUBLIC, SECOND_PRIVATE, PRIVATE_SIGNED} {
+		if steps[name].SkippedBecause != LOGIN_FIRST {
+			t.Errorf("%s: %s, want it skipped because %q failed", name, steps[name].Outcome(), LOGIN_FIRST)
+		}
+	}
+	for _, name := range []string{CREATE_FIRST, CREATE_SECOND, LOGIN_SECOND} {
+		if !steps[name].Passed() {
+			t.Errorf("%s: %s", name, steps[name].Outcome())
+		}
+	}
+}
+
+func TestProbeRetriesUnavailableDeployment(t *testing.T) {
+	fake := newFakeYoctogram(t)
+	fake.unavailable = 2
+	steps := probeFake(t, fake)
+
+	if !steps[CREATE_FIRST].Passed() {
+		t.Errorf("%s: %s", CREATE_FIRST, steps[CREATE_FIRST].Outcome())
+	}
+	if steps[CREATE_FIRST].Requests != 3 {
+		t.Errorf("%s made %d requests, want 3", CREATE_FIRST, steps[CREATE_FIRST].Requests)
+	}
+}
+
+func TestProbeIsReproducibleFromItsSeed(t *testing.T) {
+	first, second := NewAccount(rand.New(rand.NewSource(7))), NewAccount(rand.New(rand.NewSource(7)))
+	if first != second {
+		t.Errorf("the same seed made up %v and %v", first, second)
+	}
+}
+
+func TestRuntimeCheckIsWorthItsSteps(t *testing.T) {
+	check := RuntimeCheck("runtime", 4, 0, grader.Options{})
+	if check.MaxScore != 40 {
+		t.Errorf("A2's runtime check is worth %v, want 40", check.MaxScore)
+	}
+}
@@ -0,0 +1,128 @@
+package rules
+
+import future.keywords
+
+main := {
+	"pass": count(fail) == 0,
+	"violations": fail,
+}
+
+# METADATA
+# title: Secondary frontend behavior intercepts /api/* requests
+# custom:
+#   id: frontend-api-behavior-path
+#   category: compute
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	behavior := distributions[_].Properties.DistributionConfig.CacheBehaviors[_]
+	behavior.PathPattern != "/api/*"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Secondary behavior for frontend should intercept /api/* requests", [])}
+}
+
+# METADATA
+# title: Secondary frontend behavior (/api/*) disables caching
+# custom:
+#   id: frontend-api-behavior-caching
+#   category: compute
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	behavior := distributions[_].Properties.DistributionConfig.CacheBehaviors[_]
+
+	# https://docs.aws.amazon.com/AmazonCloudFront/latest/DeveloperGuide/using-managed-cache-policies.html
+	behavior.CachePolicyId != "4135ea2d-6df8-44a3-9df3-4b5a84be39ad"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Secondary behavior for frontend (/api/*) should disable caching", [])}
+}
+
+# METADATA
+# title: Primary frontend behavior directs to index.html
+# custom:
+#   id: frontend-default-root-object
+#   category: compute
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	defaultRootObject := distributions[_].Properties.DistributionConfig.DefaultRootObject
+
+	defaultRootObject != "index.html"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Primary behavior for frontend should direct to index.html", [])}
+}
+
+# METADATA
+# title: Primary frontend behavior has exactly one custom error response
+# custom:
+#   id: frontend-error-response-count
+#   category: compute
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	customErrorResponses := distributions[_].Properties.DistributionConfig.CustomErrorResponses
+
+	count(customErrorResponses) != 1
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Primary behavior for frontend should have 404 redirect to /index.html (1)", [])}
+}
+
+# METADATA
+# title: Primary frontend custom error response handles 404s
+# custom:
+#   id: frontend-error-response-code
+#   category: compute
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	customErrorResponses := distributions[_].Properties.DistributionConfig.CustomErrorResponses
+
+	customErrorResponses[0].ErrorCode != 404
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Primary behavior for frontend should have 404 redirect to /index.html (2)", [])}
+}
+
+# METADATA
+# title: Primary frontend custom error response redirects to /index.html
+# custom:
+#   id: frontend-error-response-page
+#   category: compute
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	customErrorResponses := distributions[_].Properties.DistributionConfig.CustomErrorResponses
+
+	customErrorResponses[0].ResponsePagePath != "/index.html"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Primary behavior for frontend should have 404 redirect to /index.html (3)", [])}
+}
+
+# METADATA
+# title: Secondary frontend behavior directs to api.yoctogram.SUNET.infracourse.cloud
+# custom:
+#   id: frontend-api-origin
+#   category: compute
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	domainNames := [domainName | domainName := distributions[_].Properties.DistributionConfig.Origins[_].DomainName; is_string(domainName)]
+	domainName := domainNames[_]
+
+	not startswith(domainName, "api.yoctogram")
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Primary behavior for frontend should direct to api.yoctogram.SUNET.infracourse.cloud", [])}
+}
+
+# METADATA
+# title: Frontend bucket origins use an origin access identity
+# custom:
+#   id: frontend-origin-access-identity
+#   category: compute
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	originAccessIdentityRef := [identity | identity := distributions[_].Properties.DistributionConfig.Origins[_].S3OriginConfig.OriginAccessIdentity["Fn::Join"][1][1].Ref]
+	count(originAccessIdentityRef) != 3
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Cloudfront buckets should have defined origin access identities", [])}
+}
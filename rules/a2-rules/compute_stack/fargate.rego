@@ -0,0 +1,165 @@
+package rules
+
+import future.keywords
+
+main := {
+	"pass": count(fail) == 0,
+	"violations": fail,
+}
+
+# METADATA
+# title: Exactly one ECS cluster is defined
+# custom:
+#   id: ecs-cluster-count
+#   category: compute
+#   resource_types: ["AWS::ECS::Cluster"]
+fail contains msg if {
+	clusters := [cluster | cluster := input.Resources[_]; cluster.Type == "AWS::ECS::Cluster"]
+	count(clusters) != 1
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Exactly one AWS ECS cluster should be defined", [])}
+}
+
+# METADATA
+# title: Exactly one ECS task definition is defined
+# custom:
+#   id: ecs-task-definition-count
+#   category: compute
+#   resource_types: ["AWS::ECS::TaskDefinition"]
+fail contains msg if {
+	defs := [def | def := input.Resources[_]; def.Type == "AWS::ECS::TaskDefinition"]
+	count(defs) != 1
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Exactly one AWS ECS task definition should be defined", [])}
+}
+
+# METADATA
+# title: ECS container definition has the expected environment variables
+# custom:
+#   id: ecs-container-environment
+#   category: compute
+#   resource_types: ["AWS::ECS::TaskDefinition"]
+fail contains msg if {
+	defs := [def | def := input.Resources[_]; def.Type == "AWS::ECS::TaskDefinition"]
+	env := sort([env | env := defs[_].Properties.ContainerDefinitions[_].Environment[_].Name])
+	env != ["DEBUG", "FORWARD_FACING_NAME", "PRIVATE_IMAGES_BUCKET", "PRIVATE_IMAGES_CLOUDFRONT_DISTRIBUTION", "PRODUCTION", "PUBLIC_IMAGES_BUCKET", "PUBLIC_IMAGES_CLOUDFRONT_DISTRIBUTION"]
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Environment variables for ECS container definition are incorrect", [])}
+}
+
+# METADATA
+# title: ECS container definition has the expected secrets
+# custom:
+#   id: ecs-container-secrets
+#   category: compute
+#   resource_types: ["AWS::ECS::TaskDefinition"]
+fail contains msg if {
+	defs := [def | def := input.Resources[_]; def.Type == "AWS::ECS::TaskDefinition"]
+	containerDef := defs[_].Properties.ContainerDefinitions[_]
+	not containerDef.Secrets
+	secrets := sort([containerDef | secret := containerDef.Secrets[_].Name])
+	secrets != ["POSTGRES_DB", "POSTGRES_HOST", "POSTGRES_PASSWORD", "POSTGRES_PORT", "POSTGRES_USER", "SECRET_KEY"]
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Secrets for ECS container definition are incorrect", [])}
+}
+
+# METADATA
+# title: Fargate task definition has 2048MB of memory
+# custom:
+#   id: fargate-memory
+#   category: compute
+#   resource_types: ["AWS::ECS::TaskDefinition"]
+fail contains msg if {
+	defs := [def | def := input.Resources[_]; def.Type == "AWS::ECS::TaskDefinition"]
+	defs[_].Properties.ContainerDefinitions[_].Memory != 2048
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Fargate task definition should have 2048MB of memory", [])}
+}
+
+# METADATA
+# title: Fargate task definition has 512 CPU units
+# custom:
+#   id: fargate-cpu
+#   category: compute
+#   resource_types: ["AWS::ECS::TaskDefinition"]
+fail contains msg if {
+	defs := [def | def := input.Resources[_]; def.Type == "AWS::ECS::TaskDefinition"]
+	defs[_].Properties.ContainerDefinitions[_].Cpu != 512
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Fargate task definition should have 512 CPU units", [])}
+}
+
+# METADATA
+# title: Fargate task definition runs on Linux and ARM64
+# custom:
+#   id: fargate-runtime-platform
+#   category: compute
+#   resource_types: ["AWS::ECS::TaskDefinition"]
+fail contains msg if {
+	defs := [def | def := input.Resources[_]; def.Type == "AWS::ECS::TaskDefinition"]
+	fargate_runtime_platform_flagged(object.get(defs[_].Properties, "RuntimePlatform", {}))
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Fargate task definition should run on Linux and ARM64", [])}
+}
+
+# Fargate runs a task definition without a runtime platform on Linux and
+# X86_64.
+fargate_runtime_platform_flagged(platform) if {
+	object.get(platform, "OperatingSystemFamily", "LINUX") != "LINUX"
+}
+
+fargate_runtime_platform_flagged(platform) if {
+	object.get(platform, "CpuArchitecture", "X86_64") != "ARM64"
+}
+
+# METADATA
+# title: Fargate task definition has one port mapping
+# custom:
+#   id: fargate-port-mapping-count
+#   category: compute
+#   resource_types: ["AWS::ECS::TaskDefinition"]
+fail contains msg if {
+	defs := [def | def := input.Resources[_]; def.Type == "AWS::ECS::TaskDefinition"]
+	count(defs[_].Properties.ContainerDefinitions[_].PortMappings) != 1
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Fargate task definition should have one port mapping", [])}
+}
+
+# METADATA
+# title: Fargate port mapping uses protocol tcp
+# custom:
+#   id: fargate-port-mapping-protocol
+#   category: compute
+#   resource_types: ["AWS::ECS::TaskDefinition"]
+fail contains msg if {
+	defs := [def | def := input.Resources[_]; def.Type == "AWS::ECS::TaskDefinition"]
+	defs[_].Properties.ContainerDefinitions[_].PortMappings[_].Protocol != "tcp"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Fargate task definition should have protocol tcp", [])}
+}
+
+# METADATA
+# title: Fargate port mapping uses app protocol http
+# custom:
+#   id: fargate-port-mapping-app-protocol
+#   category: compute
+#   resource_types: ["AWS::ECS::TaskDefinition"]
+fail contains msg if {
+	defs := [def | def := input.Resources[_]; def.Type == "AWS::ECS::TaskDefinition"]
+	defs[_].Properties.ContainerDefinitions[_].PortMappings[_].AppProtocol != "http"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Fargate task definition should have app protocol http", [])}
+}
+
+# METADATA
+# title: Fargate port mapping uses container port 80
+# custom:
+#   id: fargate-port-mapping-port
+#   category: compute
+#   resource_types: ["AWS::ECS::TaskDefinition"]
+fail contains msg if {
+	defs := [def | def := input.Resources[_]; def.Type == "AWS::ECS::TaskDefinition"]
+	defs[_].Properties.ContainerDefinitions[_].PortMappings[_].ContainerPort != 80
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Fargate task definition should have container port 80", [])}
+}
@@ -0,0 +1,168 @@
+package rules
+
+import future.keywords
+
+main := {
+	"pass": count(fail) == 0,
+	"violations": fail,
+}
+
+# METADATA
+# title: Exactly one Aurora DB cluster is provisioned
+# custom:
+#   id: aurora-cluster-count
+#   category: data
+#   resource_types: ["AWS::RDS::DBCluster"]
+fail contains msg if {
+	dbClusters := [db | db := input.Resources[_]; db.Type == "AWS::RDS::DBCluster"]
+	count(dbClusters) != 1
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Only one Aurora DB Cluster should be provisioned, but found zero or multiple", [])}
+}
+
+# METADATA
+# title: Aurora DB is provisioned in isolated subnets
+# custom:
+#   id: aurora-isolated-subnet
+#   category: data
+#   resource_types: ["AWS::RDS::DBSubnetGroup"]
+fail contains msg if {
+	subnetGroups := [subnetGroup | subnetGroup := input.Resources[_]; subnetGroup.Type == "AWS::RDS::DBSubnetGroup"]
+	subnetIds := subnetGroups[_].Properties.SubnetIds[_]["Fn::ImportValue"]
+	cdkLogicalSubnetIds := substring(subnetIds, count(subnetIds) - 16, 8)
+
+	subnets := [{i: subnet} | subnet := input.Resources[i]; endswith(i, cdkLogicalSubnetIds)]
+	subnet_types := [tag.Value | tag := subnets[_][_].Properties.Tags[_]; tag.Key == "aws-cdk:subnet-type"][_]
+	subnet_types != "Isolated"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Database should be provisioned in isolated subnet, but is instead in %s", [subnet_types])}
+}
+
+# METADATA
+# title: Aurora DB uses the aurora-postgresql engine
+# custom:
+#   id: aurora-engine
+#   category: data
+#   resource_types: ["AWS::RDS::DBCluster"]
+fail contains msg if {
+	dbCluster := [db | db := input.Resources[_]; db.Type == "AWS::RDS::DBCluster"]
+	engine := dbCluster[_].Properties.Engine
+	engine != "aurora-postgresql"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("RDS Aurora DB should be of type aurora-postgresql, but instead is %s", [engine])}
+}
+
+# METADATA
+# title: Aurora DB runs in serverless mode
+# custom:
+#   id: aurora-serverless
+#   category: data
+#   resource_types: ["AWS::RDS::DBCluster"]
+fail contains msg if {
+	dbCluster := [db | db := input.Resources[_]; db.Type == "AWS::RDS::DBCluster"]
+	engineMode := dbCluster[_].Properties.EngineMode
+	engineMode != "serverless"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("RDS Aurora DB should be in serverless mode, but instead is %s", [engineMode])}
+}
+
+# METADATA
+# title: Aurora DB is named yoctogram
+# custom:
+#   id: aurora-database-name
+#   category: data
+#   resource_types: ["AWS::RDS::DBCluster"]
+fail contains msg if {
+	dbCluster := [db | db := input.Resources[_]; db.Type == "AWS::RDS::DBCluster"]
+	dbName := dbCluster[_].Properties.DatabaseName
+	dbName != "yoctogram"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("RDS Aurora DB should be named yoctogram, but instead is %s", [dbName])}
+}
+
+# METADATA
+# title: Aurora DB has username yoctogram
+# custom:
+#   id: aurora-master-username
+#   category: data
+#   resource_types: ["AWS::RDS::DBCluster"]
+fail contains msg if {
+	dbCluster := [db | db := input.Resources[_]; db.Type == "AWS::RDS::DBCluster"]
+	masterUsername := dbCluster[_].Properties.MasterUsername
+	masterUsername != "yoctogram"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("RDS Aurora DB should have username yoctogram, but instead is %s", [masterUsername])}
+}
+
+# METADATA
+# title: Aurora DB credentials come from a generated secret (1)
+# custom:
+#   id: aurora-generated-secret-1
+#   category: data
+#   resource_types: ["AWS::RDS::DBCluster"]
+fail contains msg if {
+	dbCluster := [db | db := input.Resources[_]; db.Type == "AWS::RDS::DBCluster"]
+	masterUserPassword := dbCluster[_].Properties.MasterUserPassword
+	not masterUserPassword["Fn::Join"]
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("RDS Aurora DB should have a generated secret for credentials (1)", [])}
+}
+
+# METADATA
+# title: Aurora DB credentials come from a generated secret (2)
+# custom:
+#   id: aurora-generated-secret-2
+#   category: data
+#   resource_types: ["AWS::RDS::DBCluster"]
+fail contains msg if {
+	dbCluster := [db | db := input.Resources[_]; db.Type == "AWS::RDS::DBCluster"]
+	masterUserPassword := dbCluster[_].Properties.MasterUserPassword
+	not masterUserPassword["Fn::Join"][1][1].Ref
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("RDS Aurora DB should have a generated secret for credentials (2)", [])}
+}
+
+# METADATA
+# title: Aurora DB credentials come from a generated secret (3)
+# custom:
+#   id: aurora-generated-secret-3
+#   category: data
+#   resource_types: ["AWS::RDS::DBCluster"]
+fail contains msg if {
+	dbCluster := [db | db := input.Resources[_]; db.Type == "AWS::RDS::DBCluster"]
+	masterUserPassword := dbCluster[_].Properties.MasterUserPassword
+	not input.Resources[masterUserPassword["Fn::Join"][1].Ref]
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("RDS Aurora DB should have a generated secret for credentials (3)", [])}
+}
+
+# METADATA
+# title: Aurora DB credentials come from a generated secret (4)
+# custom:
+#   id: aurora-generated-secret-4
+#   category: data
+#   resource_types: ["AWS::RDS::DBCluster"]
+fail contains msg if {
+	dbCluster := [db | db := input.Resources[_]; db.Type == "AWS::RDS::DBCluster"]
+	masterUserPassword := dbCluster[_].Properties.MasterUserPassword
+	secret := input.Resources[masterUserPassword["Fn::Join"][1][1].Ref]
+	not secret.Properties.GenerateSecretString
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("RDS Aurora DB should have a generated secret for credentials (4)", [])}
+}
+
+# METADATA
+# title: Aurora DB generated secret excludes the characters given in the assignment spec
+# custom:
+#   id: aurora-secret-excluded-characters
+#   category: data
+#   resource_types: ["AWS::RDS::DBCluster"]
+fail contains msg if {
+	excludeCharacterSet := "!\"#$%&'()*+,-./:;<=>?@[\\]^`{|}~ "
+	dbCluster := [db | db := input.Resources[_]; db.Type == "AWS::RDS::DBCluster"]
+	masterUserPassword := dbCluster[_].Properties.MasterUserPassword
+	secret := input.Resources[masterUserPassword["Fn::Join"][1][1].Ref]
+	secret.Properties.GenerateSecretString.ExcludeCharacters != excludeCharacterSet
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("RDS Aurora DB generated secret should exclude characters in the given set from the generated password (check assignment spec)", [])}
+}
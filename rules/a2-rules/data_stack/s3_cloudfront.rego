@@ -0,0 +1,72 @@
+package rules
+
+import future.keywords
+
+main := {
+	"pass": count(fail) == 0,
+	"violations": fail,
+}
+
+# METADATA
+# title: Three CloudFront distributions are provisioned
+# custom:
+#   id: cloudfront-distribution-count
+#   category: data
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	count(distributions) != 3
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Three CloudFront distributions should be provisioned, but only got one", [])}
+}
+
+# METADATA
+# title: CloudFront distributions are backed by S3 buckets (1)
+# custom:
+#   id: cloudfront-s3-origin-1
+#   category: data
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	origins := distributions[_].Properties.DistributionConfig.Origins
+	bucketRef := origins[_].DomainName["Fn::GetAtt"][0]
+	not input.Resources[bucketRef]
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("CloudFront distributions should be backed by S3 buckets (1)", [])}
+}
+
+# METADATA
+# title: CloudFront distributions are backed by S3 buckets (2)
+# custom:
+#   id: cloudfront-s3-origin-2
+#   category: data
+#   resource_types: ["AWS::CloudFront::Distribution"]
+fail contains msg if {
+	distributions := [dist | dist := input.Resources[_]; dist.Type == "AWS::CloudFront::Distribution"]
+	origins := distributions[_].Properties.DistributionConfig.Origins
+	bucketRef := origins[_].DomainName["Fn::GetAtt"][0]
+	input.Resources[bucketRef].Type != "AWS::S3::Bucket"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("CloudFront distributions should be backed by S3 buckets (2)", [])}
+}
+
+# METADATA
+# title: S3 buckets block all public access
+# custom:
+#   id: s3-block-public-access
+#   category: data
+#   resource_types: ["AWS::S3::Bucket"]
+fail contains msg if {
+	buckets := [bucket | bucket := input.Resources[_]; bucket.Type == "AWS::S3::Bucket"]
+	configs := buckets[_].Properties.PublicAccessBlockConfiguration
+	not checkBucketRestricted(configs)
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("S3 buckets should block public ACLs, policies, ignore public ACLs, and restrict public buckets", [])}
+}
+
+checkBucketRestricted(config) if {
+	config.BlockPublicAcls
+	config.BlockPublicPolicy
+	config.IgnorePublicAcls
+	config.RestrictPublicBuckets
+}
@@ -0,0 +1,81 @@
+package rules
+
+import future.keywords
+
+main := {
+	"pass": count(fail) == 0,
+	"violations": fail,
+}
+
+# METADATA
+# title: Exactly one ACM certificate is provisioned
+# custom:
+#   id: acm-certificate-count
+#   category: network
+#   resource_types: ["AWS::CertificateManager::Certificate"]
+fail contains msg if {
+	certs := [cert | cert := input.Resources[_]; cert.Type == "AWS::CertificateManager::Certificate"]
+	count(certs) != 1
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("One ACM certificates should be provisioned, but found %d", [count(certs)])}
+}
+
+# METADATA
+# title: ACM certificate is validated using DNS
+# custom:
+#   id: acm-dns-validation
+#   category: network
+#   resource_types: ["AWS::CertificateManager::Certificate"]
+fail contains msg if {
+	certs := [cert | cert := input.Resources[_]; cert.Type == "AWS::CertificateManager::Certificate"]
+	validationMethod := certs[_].Properties.ValidationMethod
+	validationMethod != "DNS"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("ACM certificate should be validated using DNS", [])}
+}
+
+# METADATA
+# title: ACM certificate is issued for SUNET.infracourse.cloud
+# custom:
+#   id: acm-domain-name
+#   category: network
+#   resource_types: ["AWS::CertificateManager::Certificate"]
+fail contains msg if {
+	certs := [cert | cert := input.Resources[_]; cert.Type == "AWS::CertificateManager::Certificate"]
+	domain := certs[_].Properties.DomainName
+	not endswith(domain, ".infracourse.cloud")
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("ACM certificate should be issued for domain name SUNET.infracourse.cloud", [])}
+}
+
+# METADATA
+# title: ACM certificate has two subject alternative names under .infracourse.cloud
+# custom:
+#   id: acm-san-domain
+#   category: network
+#   resource_types: ["AWS::CertificateManager::Certificate"]
+fail contains msg if {
+	certs := [cert | cert := input.Resources[_]; cert.Type == "AWS::CertificateManager::Certificate"]
+
+	altNames := certs[_].Properties.SubjectAlternativeNames
+	count(altNames) != 2
+	not endswith(altNames, ".infracourse.cloud")
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Two subject alternative names for the backend certificate should be provisioned and end with .infracourse.cloud", [])}
+}
+
+# METADATA
+# title: ACM certificate subject alternative names are wildcards
+# custom:
+#   id: acm-san-wildcard
+#   category: network
+#   resource_types: ["AWS::CertificateManager::Certificate"]
+fail contains msg if {
+	certs := [cert | cert := input.Resources[_]; cert.Type == "AWS::CertificateManager::Certificate"]
+
+	altnames := certs[_].Properties.SubjectAlternativeNames[_]
+	count(altnames) != 1
+	not startswith(altnames, "*.")
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Certificate subject alternative names should start with *.", [])}
+}
@@ -7,25 +7,43 @@ main := {
     "violations": fail,
 }
 
+# METADATA
+# title: VPC subnets are in us-west-2a and us-west-2b
+# custom:
+#   id: subnet-availability-zones
+#   category: network
+#   resource_types: ["AWS::EC2::Subnet"]
 fail contains msg if {
     subnets := [subnet | subnet := input.Resources[_]; subnet.Type == "AWS::EC2::Subnet"]
     azs := {az | az := subnets[_].Properties.AvailabilityZone}
     azs != {"us-west-2a", "us-west-2b"}
 
-    msg := sprintf("VPC subnets must be in us-west-2a and us-west-2b availability zones, but found %s", [azs])
+    msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("VPC subnets must be in us-west-2a and us-west-2b availability zones, but found %s", [azs])}
 }
 
+# METADATA
+# title: Each availability zone has an isolated, private, and public subnet
+# custom:
+#   id: subnet-types
+#   category: network
+#   resource_types: ["AWS::EC2::Subnet"]
 fail contains msg if {
     not two_subnets_each_type
-    msg := sprintf("Must have an isolated, private, and public subnet per availability zone", [])
+    msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Must have an isolated, private, and public subnet per availability zone", [])}
 }
 
+# METADATA
+# title: Each subnet is a /24
+# custom:
+#   id: subnet-cidr-size
+#   category: network
+#   resource_types: ["AWS::EC2::Subnet"]
 fail contains msg if {
     subnets := [subnet | subnet := input.Resources[_]; subnet.Type == "AWS::EC2::Subnet"]
     cidr_blocks := subnets[_].Properties.CidrBlock
     not endswith(cidr_blocks, "/24")
 
-    msg := sprintf("Each subnet must be a CIDR /24", [])
+    msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Each subnet must be a CIDR /24", [])}
 }
 
 two_subnets_each_type {
@@ -0,0 +1,36 @@
+package rules
+
+import future.keywords
+
+main := {
+    "pass": count(fail) == 0,
+    "violations": fail,
+}
+
+# METADATA
+# title: Exactly one VPC is defined
+# description: Define a single ec2.Vpc in the network stack and share it with the other stacks
+# custom:
+#   id: vpc-count
+#   category: network
+#   resource_types: ["AWS::EC2::VPC"]
+fail contains msg if {
+    vpc := [vpc | vpc := input.Resources[_]; vpc.Type == "AWS::EC2::VPC"]
+    count(vpc) != 1
+
+    msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Only one VPC is allowed, but found zero or multiple", [])}
+}
+
+# METADATA
+# title: VPC has a CIDR block of 10.0.0.0/16
+# custom:
+#   id: vpc-cidr
+#   category: network
+#   resource_types: ["AWS::EC2::VPC"]
+fail contains msg if {
+    some vpc
+    input.Resources[vpc].Type == "AWS::EC2::VPC"
+    input.Resources[vpc].Properties.CidrBlock != "10.0.0.0/16"
+
+    msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("EC2 VPC must have a CIDR block of 10.0.0.0/16: %s", [vpc])}
+}
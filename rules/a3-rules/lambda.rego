@@ -0,0 +1,73 @@
+package rules
+
+import future.keywords
+
+main := {
+	"pass": count(fail) == 0,
+	"violations": fail,
+}
+
+# METADATA
+# title: Five Lambda functions are defined
+# custom:
+#   id: lambda-function-count
+#   category: lambda
+#   resource_types: ["AWS::Lambda::Function"]
+fail contains msg if {
+	functions := [fn | fn := input.Resources[_]; fn.Type == "AWS::Lambda::Function"]
+	count(functions) != 5
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": "Incorrect number of Lambda functions defined (should be 5: your Lambda as well as some other implicit resources)"}
+}
+
+# METADATA
+# title: Lambda function is deployed from a container image
+# custom:
+#   id: lambda-container-image
+#   category: lambda
+#   resource_types: ["AWS::Lambda::Function"]
+fail contains msg if {
+	functions := [fn | fn := input.Resources[_]; fn.Type == "AWS::Lambda::Function"; fn.Properties.PackageType == "Image"]
+	count(functions) != 1
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": "Lambda function must be deployed from a container image"}
+}
+
+# METADATA
+# title: Lambda function has 1536 MB of memory
+# custom:
+#   id: lambda-memory
+#   category: lambda
+#   resource_types: ["AWS::Lambda::Function"]
+fail contains msg if {
+	functions := [fn | fn := input.Resources[_]; fn.Type == "AWS::Lambda::Function"; fn.Properties.PackageType == "Image"]
+	functions[0].Properties.MemorySize != 1536
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": "Lambda function must have 1536 MB of memory"}
+}
+
+# METADATA
+# title: Lambda function uses ARM64 architecture
+# custom:
+#   id: lambda-architecture
+#   category: lambda
+#   resource_types: ["AWS::Lambda::Function"]
+fail contains msg if {
+	functions := [fn | fn := input.Resources[_]; fn.Type == "AWS::Lambda::Function"; fn.Properties.PackageType == "Image"]
+	functions[0].Properties.Architectures[_] != "arm64"
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": "Lambda function must use ARM64 architecture"}
+}
+
+# METADATA
+# title: Lambda function has a 30 second timeout
+# custom:
+#   id: lambda-timeout
+#   category: lambda
+#   resource_types: ["AWS::Lambda::Function"]
+fail contains msg if {
+	functions := [fn | fn := input.Resources[_]; fn.Type == "AWS::Lambda::Function"; fn.Properties.PackageType == "Image"]
+	functions[0].Properties.Timeout != 30
+
+	msg := {"id": rego.metadata.rule().custom.id, "message": "Lambda function must have a 30 second timeout"}
+}
@@ -7,14 +7,25 @@ main := {
 	"violations": fail,
 }
 
+# METADATA
+# title: Exactly one role can be assumed with sts:AssumeRoleWithWebIdentity
+# custom:
+#   id: oidc-role-count
+#   category: iam
+#   resource_types: ["AWS::IAM::Role"]
 fail contains msg if {
 	roles := [role | role := input.Resources[_]; role.Type == "AWS::IAM::Role"; role.Properties.AssumeRolePolicyDocument.Statement[0].Action == "sts:AssumeRoleWithWebIdentity"]
 	count(roles) != 1
 
-	msg := "Role under with action sts:AssumeRoleWithWebIdentity not found"
+	msg := {"id": rego.metadata.rule().custom.id, "message": "Role under with action sts:AssumeRoleWithWebIdentity not found"}
 }
 
-# Role can only be assumed by specific conditions
+# METADATA
+# title: Role can only be assumed from the main branch of your repository
+# custom:
+#   id: oidc-role-subject
+#   category: iam
+#   resource_types: ["AWS::IAM::Role"]
 fail contains msg if {
 	roles := [role | role := input.Resources[_]; role.Type == "AWS::IAM::Role"; role.Properties.AssumeRolePolicyDocument.Statement[0].Action == "sts:AssumeRoleWithWebIdentity"]
 
@@ -24,10 +35,15 @@ fail contains msg if {
 
 	not re_match(pattern, conditions[0]["token.actions.githubusercontent.com:sub"])
 
-	msg := "Role principal is improperly configured"
+	msg := {"id": rego.metadata.rule().custom.id, "message": "Role principal is improperly configured"}
 }
 
-# Role can only be assumed by specific conditions
+# METADATA
+# title: Role can only be assumed with audience sts.amazonaws.com
+# custom:
+#   id: oidc-role-audience
+#   category: iam
+#   resource_types: ["AWS::IAM::Role"]
 fail contains msg if {
 	roles := [role | role := input.Resources[_]; role.Type == "AWS::IAM::Role"; role.Properties.AssumeRolePolicyDocument.Statement[0].Action == "sts:AssumeRoleWithWebIdentity"]
 
@@ -37,22 +53,32 @@ fail contains msg if {
 
 	conditions[0]["token.actions.githubusercontent.com:aud"] != "sts.amazonaws.com"
 
-	msg := "Role principal is improperly configured"
+	msg := {"id": rego.metadata.rule().custom.id, "message": "Role principal is improperly configured"}
 }
 
-# Max session duration should be 3600
+# METADATA
+# title: Role has a maximum session duration of one hour
+# custom:
+#   id: oidc-role-session-duration
+#   category: iam
+#   resource_types: ["AWS::IAM::Role"]
 fail contains msg if {
 	roles := [role | role := input.Resources[_]; role.Type == "AWS::IAM::Role"; role.Properties.AssumeRolePolicyDocument.Statement[0].Action == "sts:AssumeRoleWithWebIdentity"]
 	session_duration := roles[_].Properties.MaxSessionDuration
 	session_duration != 3600
-	msg := "Max session duration should be one hour"
+	msg := {"id": rego.metadata.rule().custom.id, "message": "Max session duration should be one hour"}
 }
 
-# Role should ONLY have access to AmazonEC2ContainerRegistryFullAccess and AmazonECS_FullAccess
+# METADATA
+# title: Role only has AmazonEC2ContainerRegistryFullAccess and AmazonECS_FullAccess
+# custom:
+#   id: oidc-role-policies
+#   category: iam
+#   resource_types: ["AWS::IAM::Role"]
 fail contains msg if {
 	roles := [role | role := input.Resources[_]; role.Type == "AWS::IAM::Role"; role.Properties.AssumeRolePolicyDocument.Statement[_].Action == "sts:AssumeRoleWithWebIdentity"]
 	managedPolicyArnSuffixes := sort([suffix | suffix := roles[_].Properties.ManagedPolicyArns[_]["Fn::Join"][1][2]])
 	managedPolicyArnSuffixes != [":iam::aws:policy/AmazonEC2ContainerRegistryFullAccess", ":iam::aws:policy/AmazonECS_FullAccess"]
 
-	msg := sprintf("Role should ONLY have access to AmazonEC2ContainerRegistryFullAccess and AmazonECS_FullAccess", [])
+	msg := {"id": rego.metadata.rule().custom.id, "message": sprintf("Role should ONLY have access to AmazonEC2ContainerRegistryFullAccess and AmazonECS_FullAccess", [])}
 }
@@ -0,0 +1,3 @@
+module infracourse.cloud/a2-grader/rules
+
+go 1.21.6
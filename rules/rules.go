@@ -0,0 +1,11 @@
+// Package rules embeds the assignments' Rego rule bundles, so an
+// orchestrator always grades with the rules it was built with.
+package rules
+
+import "embed"
+
+// FS holds one bundle directory per assignment, e.g. a2-rules. The all:
+// prefix keeps bundle files such as .manifest.
+//
+//go:embed all:a2-rules all:a3-rules all:a4-rules
+var FS embed.FS
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A synthContext is what the orchestrator has a submission synthesized with
+// in place of lookups: the account and region its stacks are for, and the
+// cdk.context.json entries the lookups would have made. The app is never
+// given AWS access, so that synthesis comes out the same every term.
+type synthContext struct {
+	Account string                 `json:"account"`
+	Region  string                 `json:"region"`
+	Values  map[string]interface{} `json:"values"`
+}
+
+// env is the environment the app finds its account and region in.
+func (c synthContext) env() []string {
+	var env []string
+	if c.Account != "" {
+		env = append(env, "CDK_DEFAULT_ACCOUNT="+c.Account)
+	}
+	if c.Region != "" {
+		env = append(env, "CDK_DEFAULT_REGION="+c.Region)
+	}
+	return env
+}
+
+// inject adds the context's values to the cdk.context.json of the app in
+// cdkDir, in place of any of the submission's own for the same lookups.
+func (c synthContext) inject(cdkDir string) error {
+	path := filepath.Join(cdkDir, "cdk.context.json")
+	values := map[string]interface{}{}
+	contents, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(contents, &values)
+		if err != nil {
+			return &synthError{err: fmt.Errorf("the submission's cdk.context.json can't be read: %v", err)}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Println(err)
+		return err
+	}
+
+	for key, value := range c.Values {
+		values[key] = value
+	}
+
+	contents, err = json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	err = os.WriteFile(path, contents, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// A missingContextError lists the lookups an app needed that the context
+// didn't have the results of.
+type missingContextError struct {
+	keys []string
+}
+
+func (e *missingContextError) Error() string {
+	return fmt.Sprintf("the app looks up context that isn't available during grading: %s", strings.Join(e.keys, ", "))
+}
+
+// readMissing finds the lookups the app synthesized in cdkDir was missing,
+// as listed in its cloud assembly's manifest, if any.
+func readMissing(cdkDir string) *missingContextError {
+	reader := &assemblyReader{cdkDir: cdkDir, read: map[string]bool{}}
+	contents, err := reader.file(filepath.Join("cdk.out", "manifest.json"))
+	if err != nil {
+		return nil
+	}
+
+	var manifest struct {
+		Missing []struct {
+			Key string `json:"key"`
+		} `json:"missing"`
+	}
+	err = json.Unmarshal(contents, &manifest)
+	if err != nil || len(manifest.Missing) == 0 {
+		return nil
+	}
+
+	missing := &missingContextError{}
+	for _, lookup := range manifest.Missing {
+		missing.keys = append(missing.keys, lookup.Key)
+	}
+	sort.Strings(missing.keys)
+	return missing
+}
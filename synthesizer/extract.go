@@ -0,0 +1,137 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Limits on what a submission may unpack to, which keep a malicious or
+// mistaken zip from filling the lambda's disk. The app's Git history is
+// among what's packaged, so they're generous.
+const (
+	MAX_ENTRIES    = 20000
+	MAX_FILE_SIZE  = 64 * 1024 * 1024
+	MAX_TOTAL_SIZE = 256 * 1024 * 1024
+)
+
+// errMalformedZip means the upload isn't a zip at all.
+var errMalformedZip = errors.New("the uploaded submission isn't a valid zip")
+
+// An unsafeEntryError names an entry of the submission that can't be
+// extracted safely, which is the submission's problem to fix.
+type unsafeEntryError struct {
+	entry  string
+	reason string
+}
+
+func (e *unsafeEntryError) Error() string {
+	return fmt.Sprintf("submission entry %q %s", e.entry, e.reason)
+}
+
+// processUploadedZip extracts the submission into dir, rejecting entries
+// that would land outside it, links, and anything past the size limits.
+// Directories are made 0755 and files 0644, or 0755 if the zip marks them
+// executable.
+func processUploadedZip(uploadedZip []byte, dir string) error {
+	zipReader, err := zip.NewReader(bytes.NewReader(uploadedZip), int64(len(uploadedZip)))
+	if err != nil {
+		log.Println(err)
+		return fmt.Errorf("%w: %v", errMalformedZip, err)
+	}
+	if len(zipReader.File) > MAX_ENTRIES {
+		return &unsafeEntryError{entry: zipReader.File[MAX_ENTRIES].Name, reason: fmt.Sprintf("is past the limit of %d files and directories", MAX_ENTRIES)}
+	}
+
+	var total int64
+	for _, file := range zipReader.File {
+		name := strings.TrimSuffix(file.Name, "/")
+		if !filepath.IsLocal(name) || strings.Contains(name, `\`) {
+			return &unsafeEntryError{entry: file.Name, reason: "would be extracted outside the submission"}
+		}
+		path := filepath.Join(dir, name)
+
+		mode := file.Mode()
+		switch {
+		case mode.IsDir():
+			err = makeDir(file, path)
+			if err != nil {
+				return err
+			}
+			continue
+		case !mode.IsRegular():
+			return &unsafeEntryError{entry: file.Name, reason: "is a link or special file, which aren't supported"}
+		case file.UncompressedSize64 > MAX_FILE_SIZE:
+			return &unsafeEntryError{entry: file.Name, reason: fmt.Sprintf("is over the limit of %d MB per file", MAX_FILE_SIZE/1024/1024)}
+		}
+
+		perm := os.FileMode(0644)
+		if mode.Perm()&0111 != 0 {
+			perm = 0755
+		}
+		written, err := extractFile(file, path, perm, min(MAX_FILE_SIZE, MAX_TOTAL_SIZE-total))
+		if err != nil {
+			return err
+		}
+		total += written
+	}
+
+	return nil
+}
+
+// extractFile writes a file entry to path, failing once it's unpacked more
+// than limit bytes, whatever its header claims.
+func extractFile(file *zip.File, path string, perm os.FileMode, limit int64) (int64, error) {
+	err := makeDir(file, filepath.Dir(path))
+	if err != nil {
+		return 0, err
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		log.Println(err)
+		return 0, &unsafeEntryError{entry: file.Name, reason: fmt.Sprintf("can't be read: %v", err)}
+	}
+	defer reader.Close()
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, os.ErrExist) {
+			return 0, &unsafeEntryError{entry: file.Name, reason: "appears more than once, or as both a file and a directory"}
+		}
+		return 0, err
+	}
+	defer out.Close()
+
+	written, err := io.Copy(out, io.LimitReader(reader, limit+1))
+	if err != nil {
+		log.Println(err)
+		return 0, &unsafeEntryError{entry: file.Name, reason: fmt.Sprintf("can't be read: %v", err)}
+	}
+	if written > limit {
+		return 0, &unsafeEntryError{entry: file.Name, reason: fmt.Sprintf("takes the submission past the limit of %d MB per file and %d MB in all", MAX_FILE_SIZE/1024/1024, MAX_TOTAL_SIZE/1024/1024)}
+	}
+	return written, out.Close()
+}
+
+// makeDir makes the directory at path for an entry, which fails if an
+// earlier entry put a file where the directory or one of its parents goes.
+func makeDir(file *zip.File, path string) error {
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, syscall.ENOTDIR) || errors.Is(err, os.ErrExist) {
+			return &unsafeEntryError{entry: file.Name, reason: "needs a directory where an earlier entry is a file"}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// An entry is a file or directory to put in a test zip. A name ending in /
+// is a directory.
+type entry struct {
+	name     string
+	contents string
+	mode     os.FileMode
+}
+
+func newZip(t *testing.T, entries ...entry) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	writer := zip.NewWriter(buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			header.SetMode(e.mode)
+		}
+		out, err := writer.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.WriteString(out, e.contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// expectUnsafe checks that extracting upload fails on entry, and that
+// nothing was extracted outside dir.
+func expectUnsafe(t *testing.T, upload []byte, entry string) {
+	t.Helper()
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "submission")
+	err := processUploadedZip(upload, dir)
+
+	var entryErr *unsafeEntryError
+	if !errors.As(err, &entryErr) {
+		t.Fatalf("extracted with %v, want an error naming %q", err, entry)
+	}
+	if entryErr.entry != entry {
+		t.Errorf("rejected %q (%v), want %q", entryErr.entry, err, entry)
+	}
+	extracted, _ := os.ReadDir(parent)
+	for _, file := range extracted {
+		if file.Name() != "submission" {
+			t.Errorf("extracted %s outside the submission", file.Name())
+		}
+	}
+}
+
+func TestExtractsSubmission(t *testing.T) {
+	dir := t.TempDir()
+	err := processUploadedZip(newZip(t,
+		entry{name: "cdk/"},
+		entry{name: "cdk/bin/app.ts", contents: "new App();"},
+		entry{name: "cdk/run.sh", contents: "#!/bin/sh", mode: 0755},
+	), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(filepath.Join(dir, "cdk/bin/app.ts"))
+	if err != nil || string(contents) != "new App();" {
+		t.Errorf("extracted %q, %v", contents, err)
+	}
+	for name, want := range map[string]os.FileMode{"cdk/bin/app.ts": 0644, "cdk/run.sh": 0755, "cdk/bin": 0755 | os.ModeDir} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != want {
+			t.Errorf("%s is %v, want %v", name, info.Mode(), want)
+		}
+	}
+}
+
+func TestRejectsZipSlip(t *testing.T) {
+	for _, name := range []string{"../evil.sh", "cdk/../../evil.sh", "/etc/evil.sh", `cdk\..\..\evil.sh`} {
+		t.Run(name, func(t *testing.T) {
+			expectUnsafe(t, newZip(t, entry{name: "cdk/app.ts"}, entry{name: name, contents: "evil"}), name)
+		})
+	}
+}
+
+func TestRejectsSymlinks(t *testing.T) {
+	expectUnsafe(t, newZip(t, entry{name: "cdk/secrets", contents: "/etc", mode: os.ModeSymlink | 0777}), "cdk/secrets")
+}
+
+func TestRejectsTooManyEntries(t *testing.T) {
+	entries := make([]entry, MAX_ENTRIES+1)
+	for i := range entries {
+		entries[i] = entry{name: fmt.Sprintf("d%d/", i)}
+	}
+	expectUnsafe(t, newZip(t, entries...), fmt.Sprintf("d%d/", MAX_ENTRIES))
+}
+
+func TestRejectsOversizedFile(t *testing.T) {
+	expectUnsafe(t, newZip(t, entry{name: "bomb", contents: strings.Repeat("0", MAX_FILE_SIZE+1)}), "bomb")
+}
+
+func TestRejectsBombUnderstatingItsSize(t *testing.T) {
+	// The header claims a few bytes, but the data unpacks past the limit.
+	var deflated bytes.Buffer
+	writer := zip.NewWriter(&deflated)
+	out, err := writer.CreateHeader(&zip.FileHeader{Name: "bomb", Method: zip.Deflate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.WriteString(out, strings.Repeat("0", MAX_FILE_SIZE+1))
+	_ = writer.Close()
+	reader, err := zip.NewReader(bytes.NewReader(deflated.Bytes()), int64(deflated.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := reader.File[0].OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	writer = zip.NewWriter(buf)
+	header := reader.File[0].FileHeader
+	header.UncompressedSize64 = 10
+	out, err = writer.CreateRaw(&header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(out, raw)
+	_ = writer.Close()
+
+	expectUnsafe(t, buf.Bytes(), "bomb")
+}
+
+func TestRejectsFileAndDirectoryOfTheSameName(t *testing.T) {
+	for _, entries := range [][]entry{
+		{{name: "cdk", contents: "file"}, {name: "cdk/app.ts", contents: "new App();"}},
+		{{name: "cdk", contents: "file"}, {name: "cdk/"}},
+		{{name: "cdk/"}, {name: "cdk", contents: "file"}},
+		{{name: "cdk/app.ts"}, {name: "cdk/app.ts"}},
+	} {
+		expectUnsafe(t, newZip(t, entries...), entries[1].name)
+	}
+}
+
+func TestSynthHandlerRejectsBadRequests(t *testing.T) {
+	workers = make(chan struct{}, 1)
+	conflicting, err := json.Marshal(lambdaPayload{File: newZip(t, entry{name: "cdk", contents: "file"}, entry{name: "cdk/app.ts"})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for body, want := range map[string]string{
+		`{"file": `:         "malformed request",
+		string(conflicting): `"cdk/app.ts"`,
+	} {
+		recorder := httptest.NewRecorder()
+		synthHandler(recorder, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), want) {
+			t.Errorf("responded %d %q, want 400 with %s", recorder.Code, recorder.Body, want)
+		}
+	}
+}
@@ -1,191 +1,234 @@
+// Command synthesizer runs `cdk synth` on student submissions for the
+// orchestrators, which can't run student code on Gradescope. It's deployed
+// as a lambda, and serves on port 8000 when run anywhere else.
+//
+// A request is a JSON lambdaPayload: the submission zip, the curated context
+// to synthesize it with, and the stacks whose resources the rules check. The
+// response is the synthesized stacks and their merged resources. A request
+// that's malformed or whose zip can't be extracted safely is a 400, and a
+// submission that can't be synthesized a 422, both of which the orchestrator
+// shows the student; anything else that goes wrong is a 500.
 package main
 
 import (
-	"archive/zip"
-	"bytes"
 	"context"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"log"
-	"maps"
 	"net/http"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/akrylysov/algnhsa"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
-func processUploadedZip(uploadedZip []byte) error {
-	err := os.RemoveAll("/tmp/submission")
-	if err != nil {
-		log.Println(err)
-		return err
-	}
+// DEFAULT_WORKERS is how many submissions are synthesized at once unless
+// SYNTH_WORKERS says otherwise. Each runs a node process for `cdk synth`,
+// so it's kept small; further requests wait their turn.
+const DEFAULT_WORKERS = 2
 
-	err = os.MkdirAll("/tmp/submission", 0777)
-	if err != nil {
-		log.Println(err)
-		return err
-	}
+// workers holds a slot for each synthesis in progress.
+var workers chan struct{}
 
-	err = os.Chdir("/tmp/submission")
-	if err != nil {
-		log.Println(err)
-		return err
-	}
+// synthSandbox is what student code runs in.
+var synthSandbox *sandbox
 
-	zipReader, err := zip.NewReader(bytes.NewReader(uploadedZip), int64(len(uploadedZip)))
+// newWorkspace makes a directory of its own for one request's submission,
+// so that concurrent requests can't see each other's files. Remove it when
+// the request is done.
+func newWorkspace() (string, error) {
+	dir, err := os.MkdirTemp("", "submission-")
 	if err != nil {
 		log.Println(err)
-		return err
+		return "", err
 	}
+	return dir, nil
+}
 
-	for _, file := range zipReader.File {
-		fileReader, err := file.Open()
-		if err != nil {
-			log.Println(err)
-			return err
-		}
-
-		buff, err := io.ReadAll(fileReader)
-		if err != nil {
-			log.Println(err)
-			return err
-		}
-
-		if file.FileInfo().IsDir() {
-			os.MkdirAll(file.Name, os.ModePerm)
-		} else {
-			os.WriteFile(file.Name, buff, os.ModePerm)
-		}
-	}
+// A synthError means `cdk synth` itself failed, which is usually a problem
+// with the submitted code rather than with the synthesizer.
+type synthError struct {
+	err    error
+	output []byte
+}
 
-	return nil
+func (e *synthError) Error() string {
+	return fmt.Sprintf("cdk synth failed: %v\n%s", e.err, e.output)
 }
 
-func synthCDK(sdkConfig aws.Config) error {
-	stsSvc := sts.NewFromConfig(sdkConfig)
-	result, err := stsSvc.GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+// synthCDK runs `cdk synth` in the submission extracted to dir, in the
+// sandbox, with lookups disabled: whatever the app would look up must be in
+// synthContext.
+func synthCDK(ctx context.Context, synthContext synthContext, dir string) error {
+	// Avoid needing to npm install / npm run build for frontend
+	err := os.MkdirAll(filepath.Join(dir, "web/dist"), 0777)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-	accountID := *result.Account
 
-	// Avoid needing to npm install / npm run build for frontend
-	err = os.MkdirAll("web/dist", 0777)
-	if err != nil {
-		log.Println(err)
-		return err
+	cdkDir := filepath.Join(dir, "cdk")
+	if info, err := os.Stat(cdkDir); err != nil || !info.IsDir() {
+		return &synthError{err: errors.New("the submission has no cdk directory")}
 	}
 
-	err = os.Chdir("cdk")
+	err = synthContext.inject(cdkDir)
 	if err != nil {
-		return nil
+		return err
 	}
-
-	// Use the lambda's hosted AWS account ID for VPC region lookups
-	err = os.Setenv("CDK_DEFAULT_ACCOUNT", accountID)
+	err = os.RemoveAll(filepath.Join(cdkDir, "cdk.out"))
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-
-	// We don't actually care about this, it's just a convenience item
-	err = os.Setenv("SUNET", "management")
+	err = synthSandbox.prepare(dir)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
+	env := append(synthContext.env(),
+		// We don't actually care about this, it's just a convenience item
+		"SUNET=management",
+	)
 
-	cmd := exec.Command("cdk", "synth")
-	if cmd.Err != nil {
-		return cmd.Err
+	ctx, cancel := context.WithTimeout(ctx, synthSandbox.timeout)
+	defer cancel()
+	output := &tailBuffer{}
+	cmd := synthSandbox.command(ctx, dir, cdkDir, env, "cdk", "synth", "--no-lookups", "--no-notices", "--quiet")
+	err = synthSandbox.run(cmd, output)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &synthError{err: fmt.Errorf("it took longer than %v", synthSandbox.timeout), output: output.Bytes()}
+	}
+	if missing := readMissing(cdkDir); missing != nil {
+		return &synthError{err: missing, output: output.Bytes()}
 	}
-	if output, err := cmd.CombinedOutput(); err != nil {
-		log.Println(string(output))
+	if err != nil {
+		log.Println(string(output.Bytes()))
 		log.Println(err)
-		return err
+		return &synthError{err: err, output: output.Bytes()}
 	}
 
 	return nil
 }
 
-func concatFiles() (map[string]interface{}, error) {
+// mergeStacks merges the resources of the declared stacks, for the rules to
+// check as one template, alongside every stack by path. A stack is declared
+// if any part of its path is one of the names, so that a stack counts
+// whatever stage it's in and its nested stacks count with it; with no names,
+// every stack is. Two stacks defining the same logical ID differently can't
+// be merged, and are a synthError naming both. The same resource in both,
+// as CDK's singleton custom resource providers are, is merged once.
+func mergeStacks(stacks map[string]map[string]interface{}, declared []string) (map[string]interface{}, error) {
 	resources := make(map[string]interface{}, 100)
-	for _, file := range []string{
-		"cdk.out/yoctogram-dns-stack.template.json",
-		"cdk.out/yoctogram-network-stack.template.json",
-		"cdk.out/yoctogram-data-stack.template.json",
-		"cdk.out/yoctogram-compute-stack.template.json",
-	} {
-		contents, err := os.ReadFile(file)
-		if err != nil {
-			return nil, err
-		}
-
-		var topLevelKeys map[string]interface{}
-		if err := json.Unmarshal(contents, &topLevelKeys); err != nil {
-			return nil, err
+	definedIn := map[string]string{}
+	for _, path := range sortedKeys(stacks) {
+		if len(declared) > 0 && !slices.ContainsFunc(strings.Split(path, "/"), func(part string) bool { return slices.Contains(declared, part) }) {
+			continue
 		}
-
-		if resource, ok := topLevelKeys["Resources"]; ok {
-			r := resource.(map[string]interface{})
-			delete(r, "CDKMetadata")
-			maps.Copy(resources, r)
+		r, _ := stacks[path]["Resources"].(map[string]interface{})
+		for _, logicalID := range sortedKeys(r) {
+			if logicalID == "CDKMetadata" {
+				continue
+			}
+			if other, ok := definedIn[logicalID]; ok && !reflect.DeepEqual(resources[logicalID], r[logicalID]) {
+				return nil, &synthError{err: fmt.Errorf("stacks %s and %s both define a resource %s, which can't be told apart once they're merged; give one of them another ID", other, path, logicalID)}
+			}
+			definedIn[logicalID] = path
+			resources[logicalID] = r[logicalID]
 		}
 	}
 
 	return map[string]interface{}{
 		"Resources": resources,
+		"Stacks":    stacks,
 	}, nil
 }
 
+// writeSynthError responds with a 422 for a submission that couldn't be
+// synthesized, which the orchestrator shows the student, and otherwise a
+// 500.
+func writeSynthError(w http.ResponseWriter, err error) {
+	var synthErr *synthError
+	if errors.As(err, &synthErr) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	} else {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 type lambdaPayload struct {
-	File []byte `json:"file"`
+	File    []byte       `json:"file"`
+	Context synthContext `json:"context"`
+	Stacks  []string     `json:"stacks"`
 }
 
 func synthHandler(w http.ResponseWriter, r *http.Request) {
-	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	var payload lambdaPayload
+	err := json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
 		log.Println(err)
+		http.Error(w, fmt.Sprintf("malformed request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case workers <- struct{}{}:
+		defer func() { <-workers }()
+	case <-r.Context().Done():
+		http.Error(w, "gave up waiting for a synthesizer worker", http.StatusServiceUnavailable)
+		return
+	}
+
+	dir, err := newWorkspace()
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	defer func() {
+		err := os.RemoveAll(dir)
+		if err != nil {
+			log.Println(err)
+		}
+	}()
 
-	var payload lambdaPayload
-	err = json.NewDecoder(r.Body).Decode(&payload)
+	err = processUploadedZip(payload.File, dir)
 	if err != nil {
 		log.Println(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		var entryErr *unsafeEntryError
+		if errors.As(err, &entryErr) || errors.Is(err, errMalformedZip) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
-	err = processUploadedZip(payload.File)
+	err = synthCDK(r.Context(), payload.Context, dir)
 	if err != nil {
 		log.Println(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSynthError(w, err)
 		return
 	}
 
-	err = synthCDK(sdkConfig)
+	stacks, err := discoverStacks(filepath.Join(dir, "cdk"))
 	if err != nil {
 		log.Println(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSynthError(w, err)
 		return
 	}
 
-	resources, err := concatFiles()
+	merged, err := mergeStacks(stacks, payload.Stacks)
 	if err != nil {
 		log.Println(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSynthError(w, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(resources)
+	err = json.NewEncoder(w).Encode(merged)
 	if err != nil {
 		log.Println(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -194,6 +237,26 @@ func synthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == SANDBOX_INIT {
+		sandboxInit(os.Args[2:])
+	}
+
+	count := DEFAULT_WORKERS
+	if value := os.Getenv("SYNTH_WORKERS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("SYNTH_WORKERS must be a positive number, not %q", value)
+		}
+		count = parsed
+	}
+	workers = make(chan struct{}, count)
+
+	var err error
+	synthSandbox, err = newSandbox()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	http.HandleFunc("/", synthHandler)
 	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") == "" {
 		http.ListenAndServe(":8000", nil)
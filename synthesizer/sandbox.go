@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Defaults for the limits student code runs under, which SANDBOX_USER,
+// SANDBOX_TIMEOUT and SANDBOX_MEMORY_MB can override. The timeout covers the
+// whole synthesis, lookups included, and is also each process's CPU time
+// limit; the memory limit is on each process's data, so that the address
+// space node reserves but doesn't use isn't counted.
+const (
+	DEFAULT_SANDBOX_USER      = "nobody"
+	DEFAULT_SANDBOX_TIMEOUT   = 4 * time.Minute
+	DEFAULT_SANDBOX_MEMORY_MB = 2048
+)
+
+// Further limits on each process student code runs: its open files, and the
+// size of any file it writes, which is no more than a submission may unpack.
+const (
+	SANDBOX_OPEN_FILES   = 1024
+	SANDBOX_FILE_SIZE_MB = MAX_FILE_SIZE / 1024 / 1024
+)
+
+// MAX_OUTPUT is how much of what `cdk synth` prints is kept, from the end,
+// which is where its errors are.
+const MAX_OUTPUT = 64 * 1024
+
+// SANDBOX_ENV is what student code sees of the synthesizer's environment.
+// Everything else, the lambda's AWS credentials above all, is left out.
+var SANDBOX_ENV = []string{"PATH", "LANG", "LC_ALL", "TZ", "PYTHONPATH", "NODE_PATH"}
+
+// SANDBOX_NAMESPACES are the namespaces student code runs in: with no
+// network but its own loopback, and with a mount namespace of its own so
+// that it gets a /proc of its own PID namespace, which shows no other
+// processes.
+const SANDBOX_NAMESPACES = syscall.CLONE_NEWNET | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+
+// SANDBOX_INIT is the argument the synthesizer is run again with to set the
+// sandbox up from inside it, before it runs student code in its place.
+const SANDBOX_INIT = "sandbox-init"
+
+// SANDBOX_INIT_FAILED is the exit status of a sandbox that couldn't be set
+// up.
+const SANDBOX_INIT_FAILED = 125
+
+// A sandbox runs student code as a user of its own, in namespaces of its
+// own, under a seccomp filter and resource limits. Without root, which
+// Lambda never gives, there's no user or namespaces to be had, and the
+// synthesizer refuses to start unless SANDBOX_ALLOW_UNISOLATED is true.
+type sandbox struct {
+	// executable is the synthesizer, which sets the sandbox up.
+	executable string
+
+	// credential is who student code runs as, or nil to run it as the
+	// synthesizer.
+	credential *syscall.Credential
+
+	// namespaces are the clone flags student code runs with.
+	namespaces uintptr
+
+	timeout  time.Duration
+	memoryMB int
+}
+
+// sandboxLimits are what the synthesizer passes the sandbox to set up.
+type sandboxLimits struct {
+	// UID and GID are who to become, if set.
+	UID *int `json:"uid,omitempty"`
+	GID *int `json:"gid,omitempty"`
+
+	// MountProc is whether to mount a /proc of the sandbox's own.
+	MountProc bool `json:"mount_proc"`
+
+	CPUSeconds uint64 `json:"cpu_seconds"`
+	OpenFiles  uint64 `json:"open_files"`
+	FileBytes  uint64 `json:"file_bytes"`
+	DataBytes  uint64 `json:"data_bytes"`
+}
+
+// newSandbox configures the sandbox from the environment, then tries it out.
+func newSandbox() (*sandbox, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	s := &sandbox{executable: executable, timeout: DEFAULT_SANDBOX_TIMEOUT, memoryMB: DEFAULT_SANDBOX_MEMORY_MB}
+	if value := os.Getenv("SANDBOX_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("SANDBOX_TIMEOUT must be a positive duration, not %q", value)
+		}
+		s.timeout = timeout
+	}
+	if value := os.Getenv("SANDBOX_MEMORY_MB"); value != "" {
+		memoryMB, err := strconv.Atoi(value)
+		if err != nil || memoryMB <= 0 {
+			return nil, fmt.Errorf("SANDBOX_MEMORY_MB must be a positive number, not %q", value)
+		}
+		s.memoryMB = memoryMB
+	}
+	allowUnisolated := os.Getenv("SANDBOX_ALLOW_UNISOLATED") == "true"
+
+	if os.Geteuid() == 0 {
+		name := os.Getenv("SANDBOX_USER")
+		if name == "" {
+			name = DEFAULT_SANDBOX_USER
+		}
+		sandboxUser, err := user.Lookup(name)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		uid, _ := strconv.Atoi(sandboxUser.Uid)
+		gid, _ := strconv.Atoi(sandboxUser.Gid)
+		if uid == 0 {
+			return nil, fmt.Errorf("SANDBOX_USER %q is root", name)
+		}
+		s.credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+		s.namespaces = SANDBOX_NAMESPACES
+	} else if !allowUnisolated {
+		return nil, errors.New("sandbox: without root, student code can't be given a user or namespaces of its own; run the synthesizer as root, or set SANDBOX_ALLOW_UNISOLATED=true to run student code as the synthesizer's user under only the seccomp filter and resource limits")
+	}
+
+	// Student code sharing the synthesizer's user could otherwise read the
+	// synthesizer's environment, and with it the lambda's credentials,
+	// through /proc.
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_DUMPABLE, 0, 0)
+	if errno != 0 {
+		log.Println(errno)
+		return nil, errno
+	}
+
+	err = s.try()
+	if err != nil && s.namespaces != 0 && allowUnisolated {
+		log.Printf("sandbox: namespaces are unavailable (%v), but SANDBOX_ALLOW_UNISOLATED is true, so student code will share the synthesizer's network and see its processes", err)
+		s.namespaces = 0
+		err = s.try()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: student code can't be run in the sandbox: %w", err)
+	}
+	if s.credential == nil {
+		log.Println("sandbox: SANDBOX_ALLOW_UNISOLATED is true, so student code will share the synthesizer's user, network and view of processes")
+	}
+	return s, nil
+}
+
+// try runs a command in the sandbox, to find whether it can be set up.
+func (s *sandbox) try() error {
+	output := &tailBuffer{}
+	err := s.run(s.command(context.Background(), os.TempDir(), os.TempDir(), nil, "true"), output)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output.Bytes()))
+	}
+	return nil
+}
+
+// prepare hands what's in the workspace at dir over to the sandbox's user,
+// and makes the home and temporary directories student code gets there. The
+// workspace itself stays the synthesizer's, so that student code can't
+// replace the directories in it the synthesizer reads from afterwards.
+func (s *sandbox) prepare(dir string) error {
+	for _, name := range []string{".home", ".tmp"} {
+		err := os.Mkdir(filepath.Join(dir, name), 0755)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	if s.credential == nil {
+		return nil
+	}
+
+	err := os.Chmod(dir, 0755)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		err = os.Lchown(path, int(s.credential.Uid), int(s.credential.Gid))
+		if err != nil {
+			log.Println(err)
+		}
+		return err
+	})
+}
+
+// command runs name in workDir as student code, with the given environment
+// on top of SANDBOX_ENV and a home and temporary directory in the workspace
+// at dir. Run it with run. When ctx ends, every process it started is
+// killed.
+func (s *sandbox) command(ctx context.Context, dir string, workDir string, env []string, name string, args ...string) *exec.Cmd {
+	limits := sandboxLimits{
+		MountProc:  s.namespaces&syscall.CLONE_NEWNS != 0,
+		CPUSeconds: uint64(s.timeout / time.Second),
+		OpenFiles:  SANDBOX_OPEN_FILES,
+		FileBytes:  SANDBOX_FILE_SIZE_MB * 1024 * 1024,
+		DataBytes:  uint64(s.memoryMB) * 1024 * 1024,
+	}
+	if s.credential != nil {
+		uid, gid := int(s.credential.Uid), int(s.credential.Gid)
+		limits.UID, limits.GID = &uid, &gid
+	}
+	encoded, _ := json.Marshal(limits)
+
+	cmd := exec.CommandContext(ctx, s.executable, append([]string{SANDBOX_INIT, string(encoded), name}, args...)...)
+	cmd.Dir = workDir
+	cmd.Env = sandboxEnv(dir, env)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:    true,
+		Cloneflags: s.namespaces,
+		Pdeathsig:  syscall.SIGKILL,
+	}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 10 * time.Second
+	return cmd
+}
+
+// run runs a command from command, with its output going to output, and
+// kills whatever it leaves running, such as in the background, once it
+// exits. In a PID namespace the kernel does so as soon as the command
+// exits; otherwise the seccomp filter keeps every process it starts in its
+// process group.
+func (s *sandbox) run(cmd *exec.Cmd, output io.Writer) error {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		_, _ = io.Copy(output, reader)
+	}()
+
+	err = cmd.Run()
+	writer.Close()
+	if cmd.Process != nil {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	select {
+	case <-copied:
+	case <-time.After(cmd.WaitDelay):
+		// Whatever still holds the output open escaped the process group.
+		log.Println("sandbox: student code left a process running outside its process group")
+	}
+	reader.Close()
+	<-copied
+	return err
+}
+
+// sandboxInit is what the synthesizer runs as with SANDBOX_INIT, as the
+// first process in the sandbox's namespaces: it sets up what can only be
+// set up from inside, then executes the command in args in its place. It
+// never returns.
+func sandboxInit(args []string) {
+	// no_new_privs and the seccomp filter belong to the thread that sets
+	// them, which must be the one that executes the command.
+	runtime.LockOSThread()
+	err := enterSandbox(args)
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(SANDBOX_INIT_FAILED)
+}
+
+func enterSandbox(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: sandbox-init <limits> <command> [<argument>...]")
+	}
+	var limits sandboxLimits
+	err := json.Unmarshal([]byte(args[0]), &limits)
+	if err != nil {
+		return fmt.Errorf("malformed limits: %w", err)
+	}
+
+	if limits.MountProc {
+		// The new mount namespace shares the synthesizer's mounts until
+		// they're made private, and the new /proc would show up there too.
+		err = syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, "")
+		if err != nil {
+			return fmt.Errorf("making mounts private: %w", err)
+		}
+		err = syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+		if err != nil {
+			return fmt.Errorf("mounting /proc: %w", err)
+		}
+	}
+
+	for resource, limit := range map[int]uint64{
+		syscall.RLIMIT_CORE:   0,
+		syscall.RLIMIT_CPU:    limits.CPUSeconds,
+		syscall.RLIMIT_NOFILE: limits.OpenFiles,
+		syscall.RLIMIT_FSIZE:  limits.FileBytes,
+		syscall.RLIMIT_DATA:   limits.DataBytes,
+	} {
+		err = syscall.Setrlimit(resource, &syscall.Rlimit{Cur: limit, Max: limit})
+		if err != nil {
+			return fmt.Errorf("limiting resource %d: %w", resource, err)
+		}
+	}
+
+	if limits.UID != nil && limits.GID != nil {
+		err = syscall.Setgroups(nil)
+		if err == nil {
+			err = syscall.Setgid(*limits.GID)
+		}
+		if err == nil {
+			err = syscall.Setuid(*limits.UID)
+		}
+		if err != nil {
+			return fmt.Errorf("becoming user %d: %w", *limits.UID, err)
+		}
+	}
+
+	path, err := exec.LookPath(args[1])
+	if err != nil {
+		return err
+	}
+	err = installSeccomp()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, args[1:], os.Environ())
+}
+
+// sandboxEnv is the environment of student code run in the workspace at
+// dir. HTTP clients are pointed at a proxy that refuses every connection,
+// so that they fail fast should the seccomp filter not be enough, and the
+// AWS SDKs are told there are no credentials to be had, from files or the
+// instance metadata service.
+func sandboxEnv(dir string, env []string) []string {
+	var sandboxed []string
+	for _, name := range SANDBOX_ENV {
+		if value, ok := os.LookupEnv(name); ok {
+			sandboxed = append(sandboxed, name+"="+value)
+		}
+	}
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		sandboxed = append(sandboxed, name+"=http://127.0.0.1:9")
+	}
+	sandboxed = append(sandboxed,
+		"HOME="+filepath.Join(dir, ".home"),
+		"TMPDIR="+filepath.Join(dir, ".tmp"),
+		"AWS_EC2_METADATA_DISABLED=true",
+		"AWS_CONFIG_FILE=/dev/null",
+		"AWS_SHARED_CREDENTIALS_FILE=/dev/null",
+		"CDK_DISABLE_VERSION_CHECK=true",
+	)
+	return append(sandboxed, env...)
+}
+
+// A tailBuffer keeps the last MAX_OUTPUT bytes written to it.
+type tailBuffer struct {
+	data      []byte
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > 2*MAX_OUTPUT {
+		b.data = append([]byte(nil), b.data[len(b.data)-MAX_OUTPUT:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) Bytes() []byte {
+	if len(b.data) <= MAX_OUTPUT && !b.truncated {
+		return b.data
+	}
+	return append([]byte("...\n"), b.data[max(len(b.data)-MAX_OUTPUT, 0):]...)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary stand in for the synthesizer when the
+// sandbox runs it to set itself up.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == SANDBOX_INIT {
+		sandboxInit(os.Args[2:])
+	}
+	os.Exit(m.Run())
+}
+
+// sandboxes are the sandboxes the synthesizer can run student code in
+// here: isolated, if it can be, and unisolated as SANDBOX_ALLOW_UNISOLATED
+// allows.
+func sandboxes(t *testing.T) map[string]*sandbox {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("the sandbox needs root")
+	}
+	isolated, err := newSandbox()
+	if err != nil {
+		t.Skipf("the sandbox can't be set up here: %v", err)
+	}
+	unisolated := *isolated
+	unisolated.credential, unisolated.namespaces = nil, 0
+	return map[string]*sandbox{"isolated": isolated, "unisolated": &unisolated}
+}
+
+// sandboxed runs script with sh in s, in a prepared workspace, and returns
+// the workspace and what the script printed.
+func sandboxed(t *testing.T, s *sandbox, script string) (string, string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	err := s.prepare(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &tailBuffer{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = s.run(s.command(ctx, dir, dir, []string{"WORKSPACE=" + dir}, "/bin/sh", "-c", script), output)
+	return dir, strings.TrimSpace(string(output.Bytes())), err
+}
+
+func TestSandboxSeesNoOtherProcesses(t *testing.T) {
+	s := sandboxes(t)["isolated"]
+	// The shell globs /proc itself, so that it's the only process.
+	_, output, err := sandboxed(t, s, `echo /proc/[0-9]*; echo $$`)
+	if err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	if lines := strings.Fields(output); len(lines) != 2 || lines[0] != "/proc/1" || lines[1] != "1" {
+		t.Errorf("saw processes %q, want only its own", output)
+	}
+}
+
+func TestSandboxRunsAsItsOwnUser(t *testing.T) {
+	s := sandboxes(t)["isolated"]
+	_, output, err := sandboxed(t, s, `id -u`)
+	if err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	if output != fmt.Sprint(s.credential.Uid) {
+		t.Errorf("ran as %q, want %d", output, s.credential.Uid)
+	}
+}
+
+func TestSandboxKillsWhatItLeavesRunning(t *testing.T) {
+	for name, s := range sandboxes(t) {
+		t.Run(name, func(t *testing.T) {
+			dir, output, err := sandboxed(t, s, `(sleep 1; touch "$WORKSPACE/.tmp/survived") & setsid sh -c 'sleep 1; touch "$WORKSPACE/.tmp/detached"' & echo started`)
+			if err != nil || !strings.Contains(output, "started") {
+				t.Fatalf("%v: %s", err, output)
+			}
+			time.Sleep(2 * time.Second)
+			for _, name := range []string{"survived", "detached"} {
+				if _, err := os.Stat(filepath.Join(dir, ".tmp", name)); err == nil {
+					t.Errorf("a %s process outlived the sandbox", name)
+				}
+			}
+		})
+	}
+}
+
+func TestSandboxFilterRefusesSystemCalls(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("no python3 to make system calls with")
+	}
+	script := `python3 -c '
+import ctypes, os, socket
+libc = ctypes.CDLL(None, use_errno=True)
+for name, call in [
+    ("ptrace", lambda: libc.ptrace(16, os.getppid() or 1, 0, 0)),
+    ("unshare", lambda: libc.unshare(0x10000000)),
+    ("mount", lambda: libc.mount(b"none", b"/tmp", b"tmpfs", 0, None)),
+    ("setsid", lambda: libc.setsid()),
+]:
+    print(name, "refused" if call() == -1 and ctypes.get_errno() == 1 else "allowed")
+try:
+    socket.socket(socket.AF_INET, socket.SOCK_STREAM)
+    print("socket allowed")
+except PermissionError:
+    print("socket refused")
+'`
+	for name, s := range sandboxes(t) {
+		t.Run(name, func(t *testing.T) {
+			_, output, err := sandboxed(t, s, script)
+			if err != nil {
+				t.Fatalf("%v: %s", err, output)
+			}
+			for _, line := range strings.Split(output, "\n") {
+				if !strings.HasSuffix(line, " refused") {
+					t.Errorf("%s", line)
+				}
+			}
+		})
+	}
+}
+
+func TestSandboxNeedsRootOrPermission(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("the synthesizer is root")
+	}
+	t.Setenv("SANDBOX_ALLOW_UNISOLATED", "")
+	if _, err := newSandbox(); err == nil {
+		t.Error("the sandbox was set up unisolated without SANDBOX_ALLOW_UNISOLATED")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Actions a seccomp filter returns, which x/sys doesn't define.
+const (
+	SECCOMP_RET_KILL_PROCESS = 0x80000000
+	SECCOMP_RET_ERRNO        = 0x00050000
+	SECCOMP_RET_ALLOW        = 0x7fff0000
+)
+
+// Offsets into the seccomp_data a filter examines: the system call's number,
+// its architecture, and the low 32 bits of its first argument, which is
+// where both amd64 and arm64 keep it.
+const (
+	SECCOMP_DATA_NR   = 0
+	SECCOMP_DATA_ARCH = 4
+	SECCOMP_DATA_ARG0 = 16
+)
+
+// SECCOMP_DENIED are the system calls student code is refused with EPERM:
+// those that would let it look into or control other processes, change its
+// namespaces or mounts, load code into the kernel, or change the machine's
+// clock or state. setsid and setpgid are among them so that every process
+// it starts stays in the process group that's killed after it.
+var SECCOMP_DENIED = append([]uintptr{
+	unix.SYS_PTRACE, unix.SYS_PROCESS_VM_READV, unix.SYS_PROCESS_VM_WRITEV, unix.SYS_PIDFD_GETFD,
+	unix.SYS_SETSID, unix.SYS_SETPGID,
+	unix.SYS_UNSHARE, unix.SYS_SETNS,
+	unix.SYS_MOUNT, unix.SYS_UMOUNT2, unix.SYS_PIVOT_ROOT, unix.SYS_CHROOT, unix.SYS_MOUNT_SETATTR,
+	unix.SYS_OPEN_TREE, unix.SYS_MOVE_MOUNT, unix.SYS_FSOPEN, unix.SYS_FSMOUNT, unix.SYS_FSCONFIG, unix.SYS_FSPICK,
+	unix.SYS_OPEN_BY_HANDLE_AT, unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_BPF, unix.SYS_PERF_EVENT_OPEN, unix.SYS_USERFAULTFD, unix.SYS_FANOTIFY_INIT,
+	unix.SYS_IO_URING_SETUP, unix.SYS_IO_URING_ENTER, unix.SYS_IO_URING_REGISTER,
+	unix.SYS_KEYCTL, unix.SYS_ADD_KEY, unix.SYS_REQUEST_KEY,
+	unix.SYS_INIT_MODULE, unix.SYS_FINIT_MODULE, unix.SYS_DELETE_MODULE, unix.SYS_KEXEC_LOAD, unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_REBOOT, unix.SYS_SWAPON, unix.SYS_SWAPOFF, unix.SYS_ACCT, unix.SYS_QUOTACTL, unix.SYS_SYSLOG, unix.SYS_VHANGUP,
+	unix.SYS_SETTIMEOFDAY, unix.SYS_CLOCK_SETTIME, unix.SYS_CLOCK_ADJTIME, unix.SYS_ADJTIMEX,
+}, SECCOMP_DENIED_ARCH...)
+
+// SECCOMP_DENIED_NAMESPACES are the clone flags student code is refused,
+// which would put it in new namespaces, a user namespace above all.
+const SECCOMP_DENIED_NAMESPACES = unix.CLONE_NEWUSER | unix.CLONE_NEWNS | unix.CLONE_NEWNET | unix.CLONE_NEWPID |
+	unix.CLONE_NEWIPC | unix.CLONE_NEWUTS | unix.CLONE_NEWCGROUP | unix.CLONE_NEWTIME
+
+// SECCOMP_DENIED_SOCKETS are the socket families student code is refused,
+// which keeps it offline even where there's no network namespace to put it
+// in. Unix sockets, which node uses between its own processes, are left.
+var SECCOMP_DENIED_SOCKETS = []uint32{unix.AF_INET, unix.AF_INET6, unix.AF_PACKET}
+
+// seccompFilter is the BPF program that enforces the above. A system call
+// for another architecture than the synthesizer's kills the process, since
+// its numbers would mean other calls. clone3 is refused with ENOSYS, since
+// its flags can't be examined, so that the C library falls back to clone.
+func seccompFilter() []unix.SockFilter {
+	load := func(offset uint32) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: offset}
+	}
+	ret := func(action uint32) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: action}
+	}
+	// equal skips the next instruction unless the loaded value is k.
+	equal := func(k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 0, Jf: 1, K: k}
+	}
+	// notEqual skips the next skip instructions if the loaded value is k.
+	notEqual := func(k uint32, skip uint8) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 0, Jf: skip, K: k}
+	}
+	deny := ret(SECCOMP_RET_ERRNO | uint32(syscall.EPERM))
+
+	filter := []unix.SockFilter{
+		load(SECCOMP_DATA_ARCH),
+		unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 1, Jf: 0, K: SECCOMP_ARCH},
+		ret(SECCOMP_RET_KILL_PROCESS),
+		load(SECCOMP_DATA_NR),
+	}
+	filter = append(filter, seccompArchCheck()...)
+	for _, nr := range SECCOMP_DENIED {
+		filter = append(filter, equal(uint32(nr)), deny)
+	}
+	filter = append(filter, equal(unix.SYS_CLONE3), ret(SECCOMP_RET_ERRNO|uint32(syscall.ENOSYS)))
+
+	// clone: refused if it asks for any of the namespaces.
+	filter = append(filter,
+		notEqual(unix.SYS_CLONE, 4),
+		load(SECCOMP_DATA_ARG0),
+		unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K, Jt: 0, Jf: 1, K: SECCOMP_DENIED_NAMESPACES},
+		deny,
+		ret(SECCOMP_RET_ALLOW),
+	)
+
+	// socket: refused for the network's families.
+	filter = append(filter, notEqual(unix.SYS_SOCKET, uint8(1+2*len(SECCOMP_DENIED_SOCKETS))), load(SECCOMP_DATA_ARG0))
+	for _, family := range SECCOMP_DENIED_SOCKETS {
+		filter = append(filter, equal(family), deny)
+	}
+	return append(filter, ret(SECCOMP_RET_ALLOW))
+}
+
+// installSeccomp applies seccompFilter to the calling thread and whatever
+// it executes. It also sets no_new_privs, which the kernel requires of an
+// unprivileged process installing a filter, and which keeps setuid programs
+// from gaining privileges.
+func installSeccomp() error {
+	err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)
+	if err != nil {
+		return fmt.Errorf("setting no_new_privs: %w", err)
+	}
+	filter := seccompFilter()
+	program := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	err = unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&program)), 0, 0)
+	if err != nil {
+		return fmt.Errorf("installing the seccomp filter: %w", err)
+	}
+	return nil
+}
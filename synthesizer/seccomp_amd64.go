@@ -0,0 +1,23 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+// SECCOMP_ARCH is the architecture the synthesizer's system calls are for.
+const SECCOMP_ARCH = unix.AUDIT_ARCH_X86_64
+
+// SECCOMP_DENIED_ARCH are system calls only this architecture has that
+// student code is refused: access to I/O ports.
+var SECCOMP_DENIED_ARCH = []uintptr{unix.SYS_IOPL, unix.SYS_IOPERM}
+
+// X32_SYSCALL_BIT marks a system call of the x32 ABI, whose calls share the
+// architecture of amd64's but not their numbers.
+const X32_SYSCALL_BIT = 0x40000000
+
+// seccompArchCheck kills a process making an x32 system call, with the
+// system call's number loaded.
+func seccompArchCheck() []unix.SockFilter {
+	return []unix.SockFilter{
+		{Code: unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K, Jt: 0, Jf: 1, K: X32_SYSCALL_BIT},
+		{Code: unix.BPF_RET | unix.BPF_K, K: SECCOMP_RET_KILL_PROCESS},
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// MAX_ASSEMBLY_DEPTH bounds how deeply stages and nested stacks are
+// followed, should an app's cloud assembly refer back to itself.
+const MAX_ASSEMBLY_DEPTH = 8
+
+// discoverStacks reads the template of every stack the app in cdkDir
+// synthesized: those in its cloud assembly, those in its stages' assemblies,
+// and those nested in any of them. Each is keyed by its path, which is its
+// artifact ID after the IDs of the stages it's in, or for a nested stack its
+// logical ID after its parent's path, e.g. prod/yoctogram-compute-stack/
+// ServiceNestedStack.
+func discoverStacks(cdkDir string) (map[string]map[string]interface{}, error) {
+	reader := &assemblyReader{cdkDir: cdkDir, stacks: map[string]map[string]interface{}{}, read: map[string]bool{}}
+	err := reader.assembly("cdk.out", "", 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(reader.stacks) == 0 {
+		return nil, &synthError{err: errors.New("the app synthesized no stacks")}
+	}
+	return reader.stacks, nil
+}
+
+// assemblyManifest is what the synthesizer needs of a cloud assembly's
+// manifest.json.
+type assemblyManifest struct {
+	Artifacts map[string]struct {
+		Type       string `json:"type"`
+		Properties struct {
+			TemplateFile  string `json:"templateFile"`
+			DirectoryName string `json:"directoryName"`
+		} `json:"properties"`
+	} `json:"artifacts"`
+}
+
+// An assemblyReader collects the stacks of the cloud assembly in cdkDir.
+// Each file is read at most once, so that an assembly referring back to
+// itself is an error rather than endless.
+type assemblyReader struct {
+	cdkDir string
+	stacks map[string]map[string]interface{}
+	read   map[string]bool
+}
+
+// assembly adds the stacks of the cloud assembly in dir, with prefix before
+// their paths.
+func (r *assemblyReader) assembly(dir string, prefix string, depth int) error {
+	if depth > MAX_ASSEMBLY_DEPTH {
+		return &synthError{err: fmt.Errorf("the app's stages are nested more than %d deep", MAX_ASSEMBLY_DEPTH)}
+	}
+
+	contents, err := r.file(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return err
+	}
+	var manifest assemblyManifest
+	err = json.Unmarshal(contents, &manifest)
+	if err != nil {
+		log.Println(err)
+		return &synthError{err: fmt.Errorf("%s can't be read: %v", filepath.Join(dir, "manifest.json"), err)}
+	}
+
+	for _, id := range sortedKeys(manifest.Artifacts) {
+		artifact := manifest.Artifacts[id]
+		switch artifact.Type {
+		case "aws:cloudformation:stack":
+			err = r.stack(dir, artifact.Properties.TemplateFile, prefix+id, depth)
+		case "cdk:cloud-assembly":
+			err = r.assembly(filepath.Join(dir, artifact.Properties.DirectoryName), prefix+id+"/", depth+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stack adds the stack whose template is templateFile, in the assembly in
+// dir, at path, along with the stacks nested in it. A nested stack's
+// template is in the same assembly, which its resource's asset metadata
+// names.
+func (r *assemblyReader) stack(dir string, templateFile string, path string, depth int) error {
+	if depth > MAX_ASSEMBLY_DEPTH {
+		return &synthError{err: fmt.Errorf("the app's stacks are nested more than %d deep", MAX_ASSEMBLY_DEPTH)}
+	}
+
+	contents, err := r.file(filepath.Join(dir, templateFile))
+	if err != nil {
+		return err
+	}
+	var template map[string]interface{}
+	err = json.Unmarshal(contents, &template)
+	if err != nil {
+		log.Println(err)
+		return &synthError{err: fmt.Errorf("the template of stack %s can't be read: %v", path, err)}
+	}
+	r.stacks[path] = template
+
+	resources, _ := template["Resources"].(map[string]interface{})
+	for _, logicalID := range sortedKeys(resources) {
+		resource, _ := resources[logicalID].(map[string]interface{})
+		if resource["Type"] != "AWS::CloudFormation::Stack" {
+			continue
+		}
+		metadata, _ := resource["Metadata"].(map[string]interface{})
+		nestedFile, _ := metadata["aws:asset:path"].(string)
+		if !strings.HasSuffix(nestedFile, ".json") {
+			// Not a stack of the app's own, such as one from a URL.
+			continue
+		}
+		err = r.stack(dir, nestedFile, path+"/"+logicalID, depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// file reads name from the cloud assembly. Student code wrote it, so it
+// mustn't lead the synthesizer outside cdkDir, by its path or by links, to
+// anything else the synthesizer can read, nor to a file that isn't regular.
+func (r *assemblyReader) file(name string) ([]byte, error) {
+	if !filepath.IsLocal(name) {
+		return nil, &synthError{err: fmt.Errorf("the app's cloud assembly refers to %s, outside it", name)}
+	}
+
+	root, err := filepath.EvalSymlinks(r.cdkDir)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(r.cdkDir, name))
+	if err != nil {
+		log.Println(err)
+		return nil, &synthError{err: fmt.Errorf("the app's cloud assembly is missing %s", name)}
+	}
+	relative, err := filepath.Rel(root, path)
+	if err != nil || !filepath.IsLocal(relative) {
+		return nil, &synthError{err: fmt.Errorf("the app's cloud assembly links %s outside it", name)}
+	}
+	if r.read[relative] {
+		return nil, &synthError{err: fmt.Errorf("the app's cloud assembly refers to %s more than once", name)}
+	}
+	r.read[relative] = true
+
+	// Should a link be swapped in since, opening it fails, and opening a
+	// pipe doesn't wait for a writer.
+	file, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		log.Println(err)
+		return nil, &synthError{err: fmt.Errorf("the app's cloud assembly has %s, but it can't be read: %v", name, err)}
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, &synthError{err: fmt.Errorf("the app's cloud assembly has %s, but it isn't a regular file", name)}
+	}
+
+	contents, err := io.ReadAll(file)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return contents, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
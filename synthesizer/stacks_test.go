@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeJSON writes value to name under dir, making its directory.
+func writeJSON(t *testing.T, dir string, name string, value interface{}) {
+	t.Helper()
+	contents, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, name), contents, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stackArtifact and stageArtifact are manifest.json artifacts.
+func stackArtifact(templateFile string) map[string]interface{} {
+	return map[string]interface{}{"type": "aws:cloudformation:stack", "properties": map[string]interface{}{"templateFile": templateFile}}
+}
+
+func stageArtifact(directoryName string) map[string]interface{} {
+	return map[string]interface{}{"type": "cdk:cloud-assembly", "properties": map[string]interface{}{"directoryName": directoryName}}
+}
+
+// template is a stack's template defining a bucket for each logical ID.
+func template(logicalIDs ...string) map[string]interface{} {
+	resources := map[string]interface{}{"CDKMetadata": map[string]interface{}{"Type": "AWS::CDK::Metadata"}}
+	for _, id := range logicalIDs {
+		resources[id] = map[string]interface{}{"Type": "AWS::S3::Bucket"}
+	}
+	return map[string]interface{}{"Resources": resources}
+}
+
+// expectSynthError checks that err is a synthError saying want.
+func expectSynthError(t *testing.T, err error, want string) {
+	t.Helper()
+	var synthErr *synthError
+	if !errors.As(err, &synthErr) || !strings.Contains(err.Error(), want) {
+		t.Errorf("failed with %v, want a synthError saying %q", err, want)
+	}
+}
+
+func TestDiscoversStagesAndNestedStacks(t *testing.T) {
+	cdkDir := t.TempDir()
+	writeJSON(t, cdkDir, "cdk.out/manifest.json", map[string]interface{}{"artifacts": map[string]interface{}{
+		"yoctogram-dns-stack": stackArtifact("dns.template.json"),
+		"prod":                stageArtifact("assembly-prod"),
+		"Tree":                map[string]interface{}{"type": "cdk:tree"},
+	}})
+	writeJSON(t, cdkDir, "cdk.out/dns.template.json", template("Zone"))
+	writeJSON(t, cdkDir, "cdk.out/assembly-prod/manifest.json", map[string]interface{}{"artifacts": map[string]interface{}{
+		"yoctogram-compute-stack": stackArtifact("compute.template.json"),
+	}})
+	compute := template("Cluster")
+	compute["Resources"].(map[string]interface{})["ServiceNestedStack"] = map[string]interface{}{
+		"Type":     "AWS::CloudFormation::Stack",
+		"Metadata": map[string]interface{}{"aws:asset:path": "service.nested.template.json"},
+	}
+	writeJSON(t, cdkDir, "cdk.out/assembly-prod/compute.template.json", compute)
+	writeJSON(t, cdkDir, "cdk.out/assembly-prod/service.nested.template.json", template("Service"))
+
+	stacks, err := discoverStacks(cdkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := sortedKeys(stacks)
+	want := []string{"prod/yoctogram-compute-stack", "prod/yoctogram-compute-stack/ServiceNestedStack", "yoctogram-dns-stack"}
+	if fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("found %v, want %v", paths, want)
+	}
+}
+
+func TestRefusesStagesNestedTooDeep(t *testing.T) {
+	cdkDir := t.TempDir()
+	dir := "cdk.out"
+	for i := 0; i <= MAX_ASSEMBLY_DEPTH+1; i++ {
+		writeJSON(t, cdkDir, filepath.Join(dir, "manifest.json"), map[string]interface{}{"artifacts": map[string]interface{}{
+			"stage": stageArtifact("assembly-stage"),
+		}})
+		dir = filepath.Join(dir, "assembly-stage")
+	}
+	_, err := discoverStacks(cdkDir)
+	expectSynthError(t, err, fmt.Sprintf("more than %d deep", MAX_ASSEMBLY_DEPTH))
+}
+
+func TestRefusesToLeaveTheAssembly(t *testing.T) {
+	outside := t.TempDir()
+	writeJSON(t, outside, "secret.json", template("Secret"))
+
+	for name, setUp := range map[string]func(cdkDir string) error{
+		"path": func(cdkDir string) error {
+			writeJSON(t, cdkDir, "cdk.out/manifest.json", map[string]interface{}{"artifacts": map[string]interface{}{
+				"stack": stackArtifact("../../secret.json"),
+			}})
+			return nil
+		},
+		"symlink": func(cdkDir string) error {
+			writeJSON(t, cdkDir, "cdk.out/manifest.json", map[string]interface{}{"artifacts": map[string]interface{}{
+				"stack": stackArtifact("stack.template.json"),
+			}})
+			return os.Symlink(filepath.Join(outside, "secret.json"), filepath.Join(cdkDir, "cdk.out/stack.template.json"))
+		},
+		"symlinked assembly": func(cdkDir string) error {
+			writeJSON(t, cdkDir, "cdk.out/manifest.json", map[string]interface{}{"artifacts": map[string]interface{}{
+				"stage": stageArtifact("assembly-stage"),
+			}})
+			writeJSON(t, outside, "manifest.json", map[string]interface{}{"artifacts": map[string]interface{}{
+				"stack": stackArtifact("secret.json"),
+			}})
+			return os.Symlink(outside, filepath.Join(cdkDir, "cdk.out/assembly-stage"))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cdkDir := t.TempDir()
+			err := setUp(cdkDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = discoverStacks(cdkDir)
+			expectSynthError(t, err, "outside it")
+		})
+	}
+}
+
+func TestMergesOnlyDeclaredStacks(t *testing.T) {
+	stacks := map[string]map[string]interface{}{
+		"prod/yoctogram-compute-stack":                    template("Cluster"),
+		"prod/yoctogram-compute-stack/ServiceNestedStack": template("Service"),
+		"yoctogram-dns-stack":                             template("Zone"),
+		"scratch-stack":                                   template("Scratch"),
+	}
+	merged, err := mergeStacks(stacks, []string{"yoctogram-compute-stack", "yoctogram-dns-stack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources := sortedKeys(merged["Resources"].(map[string]interface{}))
+	if want := []string{"Cluster", "Service", "Zone"}; fmt.Sprint(resources) != fmt.Sprint(want) {
+		t.Errorf("merged %v, want %v", resources, want)
+	}
+	if len(merged["Stacks"].(map[string]map[string]interface{})) != len(stacks) {
+		t.Errorf("kept %d stacks, want all %d", len(merged["Stacks"].(map[string]map[string]interface{})), len(stacks))
+	}
+
+	merged, err = mergeStacks(stacks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resources := merged["Resources"].(map[string]interface{}); len(resources) != 4 {
+		t.Errorf("merged %v without declared stacks, want every stack's", sortedKeys(resources))
+	}
+}
+
+func TestRefusesCollidingLogicalIDs(t *testing.T) {
+	stacks := map[string]map[string]interface{}{
+		"yoctogram-data-stack":    template("Bucket", "Provider"),
+		"yoctogram-compute-stack": template("Provider"),
+	}
+	// The same resource in both, as a singleton provider is, merges.
+	if _, err := mergeStacks(stacks, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stacks["yoctogram-compute-stack"]["Resources"].(map[string]interface{})["Bucket"] = map[string]interface{}{"Type": "AWS::SQS::Queue"}
+	_, err := mergeStacks(stacks, nil)
+	expectSynthError(t, err, "stacks yoctogram-compute-stack and yoctogram-data-stack both define a resource Bucket")
+}